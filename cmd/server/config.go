@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/achew22/toy-project/internal/cli"
+	"github.com/achew22/toy-project/internal/config"
+)
+
+// runConfig implements the "config" command, which groups the subcommands
+// for working with configuration files.
+func runConfig(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return &cli.ExitError{Code: 2, Err: fmt.Errorf("usage: config <docs|print>")}
+	}
+
+	switch args[0] {
+	case "docs":
+		return runConfigDocs(args[1:], stdout)
+	case "print":
+		return runConfigPrint(args[1:], stdout)
+	default:
+		return &cli.ExitError{Code: 2, Err: fmt.Errorf("unknown config command %q", args[0])}
+	}
+}
+
+// runConfigDocs writes the reference documentation for every block and
+// attribute of the configuration language.
+func runConfigDocs(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("config docs", flag.ContinueOnError)
+	format := flags.String("format", "markdown", "output format, one of markdown or json")
+	if err := flags.Parse(args); err != nil {
+		return &cli.ExitError{Code: 2, Err: err}
+	}
+
+	reference := config.Reference()
+	switch *format {
+	case "markdown":
+		return reference.WriteMarkdown(stdout)
+	case "json":
+		return reference.WriteJSON(stdout)
+	default:
+		return &cli.ExitError{Code: 2, Err: fmt.Errorf("unknown format %q, expected markdown or json", *format)}
+	}
+}
+
+// runConfigPrint writes the fully evaluated configuration in a file, with
+// the source of every value and sensitive values redacted.
+func runConfigPrint(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	format := flags.String("format", "hcl", "output format, one of hcl or json")
+	if err := flags.Parse(args); err != nil {
+		return &cli.ExitError{Code: 2, Err: err}
+	}
+	if flags.NArg() != 1 {
+		return &cli.ExitError{Code: 2, Err: fmt.Errorf("usage: config print [-format=hcl|json] <file>")}
+	}
+
+	effective, err := config.EvaluateFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "hcl":
+		return effective.WriteHCL(stdout)
+	case "json":
+		return effective.WriteJSON(stdout)
+	default:
+		return &cli.ExitError{Code: 2, Err: fmt.Errorf("unknown format %q, expected hcl or json", *format)}
+	}
+}
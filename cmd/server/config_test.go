@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/achew22/toy-project/internal/goldentest"
+)
+
+// TestConfigCommand runs the config command with the arguments in each .args
+// file of testdata/config and compares what it writes against the golden
+// output.
+func TestConfigCommand(t *testing.T) {
+	goldentest.NewOneShotConfig(func(_ struct{}, filePath string, data []byte) (string, error) {
+		var stdout strings.Builder
+		if err := runConfig(strings.Fields(string(data)), &stdout); err != nil {
+			return "", err
+		}
+		return stdout.String(), nil
+	}).
+		WithInputExt(".args").
+		WithErrorExt(".txt").
+		WithErrorHandling(func(err error) []byte { return []byte(err.Error()) }).
+		RunTests(t, "testdata/config")
+}
@@ -2,10 +2,11 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+
+	"github.com/achew22/toy-project/internal/cli"
 )
 
 func main() {
@@ -13,26 +14,14 @@ func main() {
 	defer cancel()
 
 	if err := run(ctx, os.Args); err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
-		if e, ok := err.(*ExitError); ok {
-			os.Exit(e.Code)
-		} else {
-			os.Exit(1)
-		}
+		cli.Exit(err)
 	}
 }
 
-type ExitError struct {
-	Code int
-	Err  error
-}
-
-func (e *ExitError) Error() string {
-	return e.Err.Error()
-}
-
 func run(ctx context.Context, args []string) error {
-	fmt.Println("Server is running with args:", args)
-	// Simulate an error for demonstration
-	return &ExitError{Code: 2, Err: fmt.Errorf("simulated error")}
+	if len(args) > 1 && args[1] == "config" {
+		return runConfig(args[2:], os.Stdout)
+	}
+
+	return runServer(ctx, args[1:])
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/achew22/toy-project/internal/cli"
+	"github.com/achew22/toy-project/internal/config"
+	"github.com/achew22/toy-project/internal/server"
+)
+
+// defaultListeningAddress is the address the server listens on when it is
+// started without a configuration file.
+const defaultListeningAddress = "127.0.0.1:8080"
+
+// runServer starts the gRPC server and serves until ctx is done. With -config,
+// it listens on the configured address and reports the configuration through
+// the admin service.
+func runServer(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := flags.String("config", "", "configuration file to run with")
+	if err := flags.Parse(args); err != nil {
+		return &cli.ExitError{Code: 2, Err: err}
+	}
+	if flags.NArg() != 0 {
+		return &cli.ExitError{Code: 2, Err: fmt.Errorf("usage: server [-config=<file>]")}
+	}
+
+	opts, address, err := serverOptions(*configFile)
+	if err != nil {
+		return err
+	}
+	return server.NewServer(opts...).Run(ctx, address)
+}
+
+// serverOptions returns the options of a server configured by the file at
+// configFile, or of an unconfigured server if it is empty, and the address it
+// listens on.
+func serverOptions(configFile string) ([]server.Option, string, error) {
+	if configFile == "" {
+		return nil, defaultListeningAddress, nil
+	}
+	effective, err := config.EvaluateFile(configFile)
+	if err != nil {
+		return nil, "", err
+	}
+	return []server.Option{server.WithEffectiveConfig(effective)}, effective.Config.Server.ListeningAddress, nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/achew22/toy-project/internal/server/servertest"
+)
+
+// TestServeConfigured checks that a server started with -config reports its
+// configuration through the admin service.
+func TestServeConfigured(t *testing.T) {
+	opts, address, err := serverOptions("testdata/serve/server.hcl")
+	if err != nil {
+		t.Fatalf("serverOptions: %v", err)
+	}
+	if want := "127.0.0.1:8443"; address != want {
+		t.Errorf("server listens on %s, want the configured %s", address, want)
+	}
+	servertest.RunGoldenStepTestsDir(t, "testdata/serve/admin", opts...)
+}
@@ -0,0 +1,4 @@
+server {
+  listening_address = secret("file", "listening_address.secret")
+  metrics_address   = "127.0.0.1:9090"
+}
@@ -0,0 +1,3 @@
+server {
+  listening_address = secret("file", "listening_address.secret")
+}
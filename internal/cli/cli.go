@@ -0,0 +1,34 @@
+// Package cli holds what the commands of the project share.
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ExitError is an error that makes a command exit with Code, such as 2 for
+// invalid usage.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Exit prints err to stderr and exits with its code if it is an ExitError,
+// or 1 otherwise.
+func Exit(err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
+	os.Exit(1)
+}
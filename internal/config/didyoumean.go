@@ -0,0 +1,202 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/agext/levenshtein"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+
+	hcl "github.com/hashicorp/hcl/v2"
+)
+
+// suggestionThreshold is the largest edit distance at which a name is still
+// considered a likely typo of a candidate. It matches the threshold HCL uses
+// for its own suggestions so the two never disagree.
+const suggestionThreshold = 2
+
+// nameSuggestion returns the candidate closest to given by edit distance, or
+// the empty string if no candidate is close enough to be a plausible typo.
+// Ties are broken by the order of candidates.
+func nameSuggestion(given string, candidates []string) string {
+	best := ""
+	bestDist := suggestionThreshold + 1
+	for _, candidate := range candidates {
+		if dist := levenshtein.Distance(given, candidate, nil); dist < bestDist {
+			best = candidate
+			bestDist = dist
+		}
+	}
+	return best
+}
+
+// didYouMean formats a suggestion for given as a sentence that can be
+// appended to a diagnostic detail. It returns the empty string when there is
+// nothing worth suggesting.
+func didYouMean(given string, candidates []string) string {
+	return suggestionSentence(nameSuggestion(given, candidates))
+}
+
+// suggestionSentence formats suggestion, as returned by nameSuggestion, like
+// didYouMean.
+func suggestionSentence(suggestion string) string {
+	if suggestion == "" {
+		return ""
+	}
+	return fmt.Sprintf(" Did you mean %q?", suggestion)
+}
+
+// bodyContent is a replacement for hcl.Body.Content that produces better
+// diagnostics for typos. Unexpected arguments and blocks suggest the closest
+// name from the schema, and a required argument that looks like it was
+// misspelled is reported once as the typo rather than a second time as
+// missing.
+func bodyContent(body hcl.Body, schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	syntaxBody, ok := body.(*hclsyntax.Body)
+	if !ok {
+		return body.Content(schema)
+	}
+
+	// Decode with every attribute optional so that the missing argument
+	// diagnostics can be produced below, once we know which of them are
+	// explained by a typo.
+	relaxed := &hcl.BodySchema{Blocks: schema.Blocks}
+	for _, attrS := range schema.Attributes {
+		attrS.Required = false
+		relaxed.Attributes = append(relaxed.Attributes, attrS)
+	}
+	content, _, diags := syntaxBody.PartialContent(relaxed)
+
+	var attrNames []string
+	for _, attrS := range schema.Attributes {
+		if _, defined := content.Attributes[attrS.Name]; !defined {
+			attrNames = append(attrNames, attrS.Name)
+		}
+	}
+	var blockTypes []string
+	for _, blockS := range schema.Blocks {
+		blockTypes = append(blockTypes, blockS.Type)
+	}
+
+	misspelled := map[string]bool{}
+	for _, attr := range sortedAttributes(syntaxBody.Attributes) {
+		if _, defined := content.Attributes[attr.Name]; defined {
+			continue
+		}
+		closest := nameSuggestion(attr.Name, attrNames)
+		suggestion := suggestionSentence(closest)
+		if closest != "" {
+			misspelled[closest] = true
+		} else if slices.Contains(blockTypes, attr.Name) {
+			suggestion = fmt.Sprintf(" Did you mean to define a block of type %q?", attr.Name)
+		}
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported argument",
+			Detail:   fmt.Sprintf("An argument named %q is not expected here.%s", attr.Name, suggestion),
+			Subject:  attr.NameRange.Ptr(),
+		})
+	}
+
+	for _, block := range syntaxBody.Blocks {
+		if slices.Contains(blockTypes, block.Type) {
+			continue
+		}
+		suggestion := didYouMean(block.Type, blockTypes)
+		if suggestion == "" && slices.Contains(attrNames, block.Type) {
+			misspelled[block.Type] = true
+			suggestion = fmt.Sprintf(" Did you mean to define argument %q? If so, use the equals sign to assign it a value.", block.Type)
+		}
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported block type",
+			Detail:   fmt.Sprintf("Blocks of type %q are not expected here.%s", block.Type, suggestion),
+			Subject:  block.TypeRange.Ptr(),
+		})
+	}
+
+	for _, attrS := range schema.Attributes {
+		if _, defined := content.Attributes[attrS.Name]; defined || !attrS.Required || misspelled[attrS.Name] {
+			continue
+		}
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing required argument",
+			Detail:   fmt.Sprintf("The argument %q is required, but no definition was found.", attrS.Name),
+			Subject:  syntaxBody.MissingItemRange().Ptr(),
+		})
+	}
+
+	return content, diags
+}
+
+// suggestFunctions rewrites "Call to unknown function" diagnostics so that
+// they suggest the closest function available in ctx. HCL only compares the
+// unqualified part of a namespaced name against fully qualified candidates,
+// so it never finds a suggestion for names like "helloworld::thing".
+func suggestFunctions(diags hcl.Diagnostics, ctx *hcl.EvalContext) hcl.Diagnostics {
+	if ctx == nil {
+		return diags
+	}
+
+	var available []string
+	for name := range ctx.Functions {
+		available = append(available, name)
+	}
+	sort.Strings(available)
+
+	for _, diag := range diags {
+		extra, ok := hcl.DiagnosticExtra[hclsyntax.FunctionCallUnknownDiagExtra](diag)
+		if !ok {
+			continue
+		}
+		name := extra.CalledFunctionNamespace() + extra.CalledFunctionName()
+		diag.Detail = fmt.Sprintf("There is no function named %q.%s", name, didYouMean(name, available))
+	}
+	return diags
+}
+
+// suggestAttributes rewrites "Unsupported attribute" diagnostics about
+// references to variables, such as self.listenin_address, so that they suggest
+// the closest attribute of the object. HCL already suggests the closest name
+// for a misspelled variable, but not for its attributes.
+func suggestAttributes(diags hcl.Diagnostics) hcl.Diagnostics {
+	for _, diag := range diags {
+		expr, ok := diag.Expression.(*hclsyntax.ScopeTraversalExpr)
+		if !ok || diag.Summary != "Unsupported attribute" || diag.Subject == nil {
+			continue
+		}
+		for i, step := range expr.Traversal {
+			attr, ok := step.(hcl.TraverseAttr)
+			if !ok || attr.SrcRange != *diag.Subject {
+				continue
+			}
+			object, objectDiags := expr.Traversal[:i].TraverseAbs(diag.EvalContext)
+			if objectDiags.HasErrors() || !object.Type().IsObjectType() {
+				break
+			}
+			var names []string
+			for name := range object.Type().AttributeTypes() {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			diag.Detail += didYouMean(attr.Name, names)
+			break
+		}
+	}
+	return diags
+}
+
+// sortedAttributes returns the attributes of a body in source order so that
+// diagnostics about them are reported deterministically.
+func sortedAttributes(attrs hclsyntax.Attributes) []*hclsyntax.Attribute {
+	sorted := make([]*hclsyntax.Attribute, 0, len(attrs))
+	for _, attr := range attrs {
+		sorted = append(sorted, attr)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].SrcRange.Start.Byte < sorted[j].SrcRange.Start.Byte
+	})
+	return sorted
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	hcl "github.com/hashicorp/hcl/v2"
+)
+
+// Redacted is shown in place of the value of a sensitive attribute.
+const Redacted = "(sensitive)"
+
+// Effective is a fully evaluated configuration together with where each of
+// its values came from.
+type Effective struct {
+	Config *Config
+
+	sources   map[string]hcl.Range
+	sensitive map[string]bool
+}
+
+// Value is a single attribute of an effective configuration.
+type Value struct {
+	// Path is the path of the attribute, e.g. ["server", "listening_address"].
+	Path []string
+	// Value is the value the server runs with. Sensitive values are not
+	// redacted; use Redacted to get a value that is safe to show.
+	Value cty.Value
+	// Source is the range of the expression that set the attribute, or nil
+	// if the attribute was not set.
+	Source *hcl.Range
+	// Default reports whether the value comes from the attribute's default.
+	Default bool
+	// Sensitive reports whether the attribute is tagged sensitive in the
+	// schema or its value came from a secret.
+	Sensitive bool
+}
+
+// Redacted returns the value of v, or Redacted if v is sensitive.
+func (v Value) Redacted() cty.Value {
+	if v.Sensitive {
+		return cty.StringVal(Redacted)
+	}
+	return v.Value
+}
+
+// Provenance describes where v came from: the file and range that set it,
+// "default" or "unset".
+func (v Value) Provenance() string {
+	switch {
+	case v.Source != nil:
+		return v.Source.String()
+	case v.Default:
+		return "default"
+	default:
+		return "unset"
+	}
+}
+
+// Values returns every attribute of the configuration in schema order,
+// including the ones that were not set.
+func (e *Effective) Values() []Value {
+	var values []Value
+	e.walk(configSchema, nil, reflect.ValueOf(e.Config).Elem(), func(v Value) {
+		values = append(values, v)
+	}, nil)
+	return values
+}
+
+// walk calls attrFn for every attribute of schema and blockFn when entering
+// (open is true) and leaving a nested block.
+func (e *Effective) walk(schema *blockSchema, path []string, target reflect.Value, attrFn func(Value), blockFn func(name string, open bool)) {
+	for _, field := range schema.Fields {
+		fieldPath := append(path[:len(path):len(path)], field.Name)
+		fieldValue := target.Field(field.Index)
+		switch field.Kind {
+		case attributeKind:
+			v := Value{
+				Path:      fieldPath,
+				Sensitive: field.Sensitive || e.sensitive[strings.Join(fieldPath, ".")],
+			}
+			ty, err := gocty.ImpliedType(fieldValue.Interface())
+			if err == nil {
+				v.Value, err = gocty.ToCtyValue(fieldValue.Interface(), ty)
+			}
+			if err != nil {
+				v.Value = cty.DynamicVal
+			}
+			if source, ok := e.sources[strings.Join(fieldPath, ".")]; ok {
+				v.Source = &source
+			} else {
+				v.Default = field.HasDefault
+			}
+			attrFn(v)
+		case blockKind:
+			if blockFn != nil {
+				blockFn(field.Name, true)
+			}
+			e.walk(field.Block, fieldPath, fieldValue, attrFn, blockFn)
+			if blockFn != nil {
+				blockFn(field.Name, false)
+			}
+		}
+	}
+}
+
+// WriteHCL writes the effective configuration in HCL, with a comment after
+// every attribute saying where its value came from. Sensitive values are
+// redacted.
+func (e *Effective) WriteHCL(w io.Writer) error {
+	var b strings.Builder
+	depth := 0
+	e.walk(configSchema, nil, reflect.ValueOf(e.Config).Elem(), func(v Value) {
+		fmt.Fprintf(&b, "%s%s = %s # %s\n",
+			strings.Repeat("  ", depth),
+			v.Path[len(v.Path)-1],
+			strings.TrimSpace(string(hclwrite.TokensForValue(v.Redacted()).Bytes())),
+			v.Provenance())
+	}, func(name string, open bool) {
+		if open {
+			fmt.Fprintf(&b, "%s%s {\n", strings.Repeat("  ", depth), name)
+			depth++
+			return
+		}
+		depth--
+		fmt.Fprintf(&b, "%s}\n", strings.Repeat("  ", depth))
+	})
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// effectiveJSONValue is the JSON representation of a single attribute.
+type effectiveJSONValue struct {
+	Value     json.RawMessage `json:"value"`
+	Source    string          `json:"source"`
+	Sensitive bool            `json:"sensitive,omitempty"`
+}
+
+// WriteJSON writes the effective configuration as JSON. Blocks are objects
+// and every attribute is an object holding its value and where it came from.
+// Sensitive values are redacted.
+func (e *Effective) WriteJSON(w io.Writer) error {
+	root := map[string]any{}
+	for _, v := range e.Values() {
+		value := v.Redacted()
+		raw, err := ctyjson.Marshal(value, value.Type())
+		if err != nil {
+			return fmt.Errorf("marshaling %s: %w", strings.Join(v.Path, "."), err)
+		}
+
+		obj := root
+		for _, name := range v.Path[:len(v.Path)-1] {
+			child, ok := obj[name].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				obj[name] = child
+			}
+			obj = child
+		}
+		obj[v.Path[len(v.Path)-1]] = effectiveJSONValue{
+			Value:     raw,
+			Source:    v.Provenance(),
+			Sensitive: v.Sensitive,
+		}
+	}
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
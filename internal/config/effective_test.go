@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/achew22/toy-project/internal/goldentest"
+)
+
+func init() {
+	RegisterSecretProvider("fake", SecretProviderFunc(func(ref string) (string, error) {
+		if ref != "host" {
+			return "", fmt.Errorf("no secret named %q", ref)
+		}
+		return "10.0.0.2", nil
+	}))
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	goldentest.NewOneShotConfig(func(_ struct{}, filePath string, data []byte) (string, error) {
+		effective, diags := Evaluate(filePath, data)
+		if diags.HasErrors() {
+			return "", diags
+		}
+
+		var b strings.Builder
+		b.WriteString("# HCL\n")
+		if err := effective.WriteHCL(&b); err != nil {
+			return "", err
+		}
+		b.WriteString("\n# JSON\n")
+		if err := effective.WriteJSON(&b); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}).
+		WithInputExt(".hcl").
+		RunTests(t, "testdata/effective")
+}
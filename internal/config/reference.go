@@ -0,0 +1,117 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// BlockReference documents a block of the configuration language and
+// everything it may contain. The root reference has an empty Name and
+// documents the top level of a configuration file.
+type BlockReference struct {
+	Name       string               `json:"name,omitempty"`
+	Doc        string               `json:"doc,omitempty"`
+	Attributes []AttributeReference `json:"attributes,omitempty"`
+	Blocks     []BlockReference     `json:"blocks,omitempty"`
+}
+
+// AttributeReference documents a single attribute of a block.
+type AttributeReference struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Doc         string   `json:"doc,omitempty"`
+	Required    bool     `json:"required"`
+	Default     *string  `json:"default,omitempty"`
+	Validations []string `json:"validations,omitempty"`
+}
+
+// Reference returns the reference documentation for the configuration
+// language, generated from the same schema that is used to decode it.
+func Reference() BlockReference {
+	return configSchema.reference()
+}
+
+func (s *blockSchema) reference() BlockReference {
+	ref := BlockReference{Name: s.Name, Doc: s.Doc}
+	for _, field := range s.Fields {
+		switch field.Kind {
+		case attributeKind:
+			attr := AttributeReference{
+				Name:     field.Name,
+				Doc:      field.Doc,
+				Required: field.Required,
+			}
+			if ty, err := gocty.ImpliedType(reflect.Zero(field.Type).Interface()); err == nil {
+				attr.Type = ty.FriendlyName()
+			}
+			if field.HasDefault {
+				attr.Default = &field.Default
+			}
+			for _, v := range field.Validate {
+				attr.Validations = append(attr.Validations, validators[v].Doc)
+			}
+			ref.Attributes = append(ref.Attributes, attr)
+		case blockKind:
+			ref.Blocks = append(ref.Blocks, field.Block.reference())
+		}
+	}
+	return ref
+}
+
+// WriteJSON writes the reference as indented JSON.
+func (r BlockReference) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// WriteMarkdown writes the reference as a Markdown document with a section
+// per block.
+func (r BlockReference) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Configuration Reference\n")
+	fmt.Fprintf(&b, "\n%s\n", validationReference)
+	fmt.Fprintf(&b, "\n%s\n", variableReference)
+	r.writeMarkdown(&b, nil)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (r BlockReference) writeMarkdown(b *strings.Builder, path []string) {
+	if r.Name != "" {
+		path = append(path, r.Name)
+		fmt.Fprintf(b, "\n%s `%s` block\n", strings.Repeat("#", len(path)+1), strings.Join(path, "."))
+	}
+	if r.Doc != "" {
+		fmt.Fprintf(b, "\n%s\n", r.Doc)
+	}
+
+	if len(r.Attributes) > 0 {
+		b.WriteString("\n| Attribute | Type | Required | Default | Description |\n")
+		b.WriteString("|-----------|------|----------|---------|-------------|\n")
+		for _, attr := range r.Attributes {
+			required := "no"
+			if attr.Required {
+				required = "yes"
+			}
+			def := ""
+			if attr.Default != nil {
+				def = fmt.Sprintf("`%q`", *attr.Default)
+			}
+			desc := strings.Join(append([]string{attr.Doc}, attr.Validations...), " ")
+			fmt.Fprintf(b, "| `%s` | %s | %s | %s | %s |\n", attr.Name, attr.Type, required, def, strings.TrimSpace(desc))
+		}
+	}
+
+	for _, block := range r.Blocks {
+		block.writeMarkdown(b, path)
+	}
+}
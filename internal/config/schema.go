@@ -0,0 +1,299 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/gocty"
+
+	hcl "github.com/hashicorp/hcl/v2"
+)
+
+// The schema of the configuration language is declared with struct tags on
+// the Go types it decodes into, so that decoding, defaulting, validation and
+// the reference documentation are all driven by a single definition:
+//
+//	type ServerConfig struct {
+//		ListeningAddress string `hcl:"listening_address,attr" validate:"hostport" doc:"..."`
+//	}
+//
+// The hcl tag names the attribute or block and its kind. Attributes are
+// required unless the kind is "optional" or the field has a default tag, whose
+// value is converted to the field's type. Blocks must be struct fields and
+// appear exactly once. The validate tag is a comma separated list of keys in
+// validators.
+
+// blockSchema describes a block (or the top-level body of a file) and the
+// items it may contain.
+type blockSchema struct {
+	Name   string
+	Doc    string
+	Fields []fieldSchema
+}
+
+// fieldSchema describes a single attribute or nested block of a blockSchema.
+type fieldSchema struct {
+	Name     string
+	Kind     bodyItem
+	Doc      string
+	Required bool
+	Default  string
+	// HasDefault distinguishes an empty default from no default at all.
+	HasDefault bool
+	Validate   []string
+	Sensitive  bool
+	// Index is the index of the struct field the item decodes into.
+	Index int
+	Type  reflect.Type
+	// Block is the schema of a nested block. It is only set for blocks.
+	Block *blockSchema
+}
+
+// validator checks a decoded attribute value. name is the attribute name,
+// used to build the returned diagnostic. A nil return means value is valid.
+// The diagnostic must not include the value, which may be sensitive.
+type validator struct {
+	Doc   string
+	Check func(name string, value reflect.Value) *hcl.Diagnostic
+}
+
+// validators are the checks that can be named in a field's validate tag.
+var validators = map[string]validator{
+	"hostport": {
+		Doc: "Must be in the format `host:port`.",
+		Check: func(name string, value reflect.Value) *hcl.Diagnostic {
+			host, port, err := net.SplitHostPort(value.String())
+			if err == nil && host != "" && port != "" {
+				return nil
+			}
+			return &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid " + strings.ReplaceAll(name, "_", " "),
+				Detail:   fmt.Sprintf("The '%s' must be in the format 'host:port'.", name),
+			}
+		},
+	},
+}
+
+// configSchema is the schema of a configuration file.
+var configSchema = mustSchemaOf("", "", reflect.TypeOf(Config{}))
+
+// mustSchemaOf is like schemaOf but panics on a malformed schema, which is
+// always a programming error.
+func mustSchemaOf(name, doc string, typ reflect.Type) *blockSchema {
+	schema, err := schemaOf(name, doc, typ)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// schemaOf builds the schema of a block from the struct tags of typ.
+func schemaOf(name, doc string, typ reflect.Type) (*blockSchema, error) {
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("block %q: %s is not a struct", name, typ)
+	}
+
+	schema := &blockSchema{Name: name, Doc: doc}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, ok := field.Tag.Lookup("hcl")
+		if !ok {
+			continue
+		}
+
+		itemName, kind, _ := strings.Cut(tag, ",")
+		if itemName == validationBlockType || itemName == variableBlockType {
+			return nil, fmt.Errorf("field %s.%s: %q is reserved for %s blocks", typ.Name(), field.Name, itemName, itemName)
+		}
+		fs := fieldSchema{
+			Name:  itemName,
+			Doc:   field.Tag.Get("doc"),
+			Index: i,
+			Type:  field.Type,
+		}
+		fs.Default, fs.HasDefault = field.Tag.Lookup("default")
+		fs.Sensitive = field.Tag.Get("sensitive") == "true"
+		if validate := field.Tag.Get("validate"); validate != "" {
+			fs.Validate = strings.Split(validate, ",")
+		}
+		for _, v := range fs.Validate {
+			if _, ok := validators[v]; !ok {
+				return nil, fmt.Errorf("field %s.%s: unknown validator %q", typ.Name(), field.Name, v)
+			}
+		}
+
+		switch kind {
+		case "attr", "optional":
+			fs.Kind = attributeKind
+			fs.Required = kind == "attr" && !fs.HasDefault
+			if _, err := gocty.ImpliedType(reflect.Zero(field.Type).Interface()); err != nil {
+				return nil, fmt.Errorf("field %s.%s: %w", typ.Name(), field.Name, err)
+			}
+		case "block":
+			fs.Kind = blockKind
+			fs.Required = true
+			block, err := schemaOf(itemName, fs.Doc, field.Type)
+			if err != nil {
+				return nil, err
+			}
+			fs.Block = block
+		default:
+			return nil, fmt.Errorf("field %s.%s: unknown kind %q in hcl tag", typ.Name(), field.Name, kind)
+		}
+		schema.Fields = append(schema.Fields, fs)
+	}
+	return schema, nil
+}
+
+// bodySchema returns the hcl.BodySchema that matches s.
+func (s *blockSchema) bodySchema() *hcl.BodySchema {
+	bodySchema := &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: validationBlockType}},
+	}
+	if s.Name == "" {
+		bodySchema.Blocks = append(bodySchema.Blocks, hcl.BlockHeaderSchema{
+			Type:       variableBlockType,
+			LabelNames: []string{"name"},
+		})
+	}
+	for _, field := range s.Fields {
+		switch field.Kind {
+		case attributeKind:
+			bodySchema.Attributes = append(bodySchema.Attributes, hcl.AttributeSchema{
+				Name:     field.Name,
+				Required: field.Required,
+			})
+		case blockKind:
+			bodySchema.Blocks = append(bodySchema.Blocks, hcl.BlockHeaderSchema{
+				Type: field.Name,
+			})
+		}
+	}
+	return bodySchema
+}
+
+// decoder decodes a configuration body into Go values and records where
+// every attribute it decodes was defined.
+type decoder struct {
+	ctx *hcl.EvalContext
+	// sources holds the range of the expression that defined each attribute,
+	// keyed by its dotted path. Attributes that were not set are absent.
+	sources map[string]hcl.Range
+	// sensitive holds the dotted paths of attributes whose value was marked
+	// sensitive, e.g. because it came from the secret function.
+	sensitive map[string]bool
+}
+
+func newDecoder(ctx *hcl.EvalContext) *decoder {
+	return &decoder{ctx: ctx, sources: map[string]hcl.Range{}, sensitive: map[string]bool{}}
+}
+
+// decodeBody decodes body into the struct pointed to by target according to
+// schema, applying defaults and running validators. path is the path of the
+// block being decoded and is empty for the top level of a file.
+func (d *decoder) decodeBody(body hcl.Body, schema *blockSchema, path []string, target reflect.Value) hcl.Diagnostics {
+	content, diags := bodyContent(body, schema.bodySchema())
+	if diags.HasErrors() {
+		return diags
+	}
+	if len(path) == 0 {
+		// Variables are evaluated first, since any expression of the file may
+		// refer to them.
+		diags = diags.Extend(d.decodeVariables(content.Blocks.OfType(variableBlockType)))
+		if diags.HasErrors() {
+			return diags
+		}
+	}
+
+	for _, field := range schema.Fields {
+		fieldValue := target.Field(field.Index)
+		fieldPath := append(path[:len(path):len(path)], field.Name)
+		switch field.Kind {
+		case attributeKind:
+			diags = diags.Extend(d.decodeAttribute(content.Attributes[field.Name], field, fieldPath, fieldValue))
+		case blockKind:
+			blocks := content.Blocks.OfType(field.Name)
+			if len(blocks) != 1 {
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  fmt.Sprintf("Expected exactly one %s block", field.Name),
+					Detail:   fmt.Sprintf("You provided a non-one number of %s blocks.", field.Name),
+				})
+			}
+			for _, block := range blocks {
+				diags = diags.Extend(d.decodeBody(block.Body, field.Block, fieldPath, fieldValue))
+			}
+		}
+	}
+
+	// Custom validations only make sense once the block decoded cleanly.
+	if !diags.HasErrors() {
+		validations := content.Blocks.OfType(validationBlockType)
+		diags = diags.Extend(d.checkValidations(validations, d.selfValue(schema, path, target)))
+	}
+	return diags
+}
+
+// decodeAttribute evaluates attr and stores the result in target, or stores
+// the field's default if attr is nil.
+func (d *decoder) decodeAttribute(attr *hcl.Attribute, field fieldSchema, path []string, target reflect.Value) hcl.Diagnostics {
+	var (
+		value  cty.Value
+		source *hcl.Range
+		diags  hcl.Diagnostics
+	)
+	switch {
+	case attr != nil:
+		var valueDiags hcl.Diagnostics
+		value, valueDiags = attr.Expr.Value(d.ctx)
+		diags = diags.Extend(suggestAttributes(suggestFunctions(valueDiags, d.ctx)))
+		if valueDiags.HasErrors() {
+			return diags
+		}
+		source = attr.Expr.Range().Ptr()
+	case field.HasDefault:
+		value = cty.StringVal(field.Default)
+	default:
+		return nil
+	}
+
+	// Marks cannot be stored in Go values, so remember the one that matters
+	// and strip them all before conversion.
+	value, marks := value.UnmarkDeep()
+	if _, ok := marks[sensitiveMark]; ok {
+		d.sensitive[strings.Join(path, ".")] = true
+	}
+
+	ty, err := gocty.ImpliedType(target.Interface())
+	if err == nil {
+		value, err = convert.Convert(value, ty)
+	}
+	if err == nil {
+		err = gocty.FromCtyValue(value, target.Addr().Interface())
+	}
+	if err != nil {
+		return diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Incorrect attribute value type",
+			Detail:   fmt.Sprintf("Inappropriate value for attribute %q: %s.", field.Name, err),
+			Subject:  source,
+		})
+	}
+
+	if source != nil {
+		d.sources[strings.Join(path, ".")] = *source
+	}
+
+	for _, v := range field.Validate {
+		if diag := validators[v].Check(field.Name, target); diag != nil {
+			diag.Subject = source
+			diags = diags.Append(diag)
+		}
+	}
+	return diags
+}
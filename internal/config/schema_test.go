@@ -0,0 +1,101 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+
+	hcl "github.com/hashicorp/hcl/v2"
+)
+
+type testListener struct {
+	Address string `hcl:"address,attr" validate:"hostport" doc:"Where to listen."`
+	Backlog int    `hcl:"backlog,attr" default:"128" doc:"Pending connection limit."`
+	Name    string `hcl:"name,optional"`
+}
+
+type testConfig struct {
+	Listener testListener `hcl:"listener,block" doc:"A listener."`
+}
+
+func decodeTestConfig(t *testing.T, src string) (testConfig, hcl.Diagnostics) {
+	t.Helper()
+	file, diags := hclsyntax.ParseConfig([]byte(src), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatalf("ParseConfig: %v", diags)
+	}
+	schema := mustSchemaOf("", "", reflect.TypeOf(testConfig{}))
+	var config testConfig
+	diags = newDecoder(evalContext("test.hcl")).decodeBody(file.Body, schema, nil, reflect.ValueOf(&config).Elem())
+	return config, diags
+}
+
+func TestDecodeBody(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		config, diags := decodeTestConfig(t, `listener { address = "localhost:80" }`)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		want := testConfig{Listener: testListener{Address: "localhost:80", Backlog: 128}}
+		if diff := cmp.Diff(want, config); diff != "" {
+			t.Errorf("decoded config mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("conversion", func(t *testing.T) {
+		config, diags := decodeTestConfig(t, `listener {
+  address = "localhost:80"
+  backlog = "16"
+  name    = 7
+}`)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+		want := testConfig{Listener: testListener{Address: "localhost:80", Backlog: 16, Name: "7"}}
+		if diff := cmp.Diff(want, config); diff != "" {
+			t.Errorf("decoded config mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("incorrect type", func(t *testing.T) {
+		_, diags := decodeTestConfig(t, `listener {
+  address = "localhost:80"
+  backlog = "many"
+}`)
+		if len(diags) != 1 || diags[0].Summary != "Incorrect attribute value type" {
+			t.Fatalf("expected a single type diagnostic, got: %v", diags)
+		}
+		if got, want := diags[0].Subject.Start.Line, 3; got != want {
+			t.Errorf("diagnostic is on line %d, want %d", got, want)
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		_, diags := decodeTestConfig(t, `listener { address = "localhost" }`)
+		if len(diags) != 1 || diags[0].Summary != "Invalid address" {
+			t.Fatalf("expected a single validation diagnostic, got: %v", diags)
+		}
+	})
+}
+
+func TestSchemaOfRejectsMalformedTags(t *testing.T) {
+	for name, typ := range map[string]reflect.Type{
+		"unknown kind": reflect.TypeOf(struct {
+			A string `hcl:"a,attribute"`
+		}{}),
+		"unknown validator": reflect.TypeOf(struct {
+			A string `hcl:"a,attr" validate:"bogus"`
+		}{}),
+		"non-struct block": reflect.TypeOf(struct {
+			A string `hcl:"a,block"`
+		}{}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := schemaOf("", "", typ); err == nil {
+				t.Error("expected an error, got none")
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// sensitiveMark is the cty mark carried by values that must never be shown,
+// such as the results of the secret function. Attributes whose value carries
+// it are treated like attributes tagged sensitive:"true".
+const sensitiveMark = mark("sensitive")
+
+// mark is the type of the cty marks defined by this package, so that they
+// cannot collide with marks defined elsewhere.
+type mark string
+
+// SecretProvider is a backend for the secret function. Configurations call
+// secret(provider, ref) and the provider registered under that name resolves
+// ref to the secret value.
+type SecretProvider interface {
+	// Secret returns the secret identified by ref.
+	Secret(ref string) (string, error)
+}
+
+// SecretProviderFunc adapts a function to a SecretProvider.
+type SecretProviderFunc func(ref string) (string, error)
+
+// Secret calls f(ref).
+func (f SecretProviderFunc) Secret(ref string) (string, error) {
+	return f(ref)
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProvider{
+		"env":  SecretProviderFunc(envSecret),
+		"file": fileSecrets{},
+	}
+)
+
+// RegisterSecretProvider makes a secret provider available to configurations
+// under name. It panics if a provider is already registered under name.
+func RegisterSecretProvider(name string, provider SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	if _, dup := secretProviders[name]; dup {
+		panic(fmt.Sprintf("config: RegisterSecretProvider called twice for provider %q", name))
+	}
+	secretProviders[name] = provider
+}
+
+// lookupSecretProvider returns the provider registered under name and the
+// sorted names of all providers, for suggestions.
+func lookupSecretProvider(name string) (SecretProvider, []string) {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+	names := make([]string, 0, len(secretProviders))
+	for n := range secretProviders {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return secretProviders[name], names
+}
+
+// envSecret reads a secret from the environment variable named ref.
+func envSecret(ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %q is not set", ref)
+	}
+	return value, nil
+}
+
+// fileSecrets reads secrets from the file at path ref. A trailing newline is
+// not part of the secret.
+type fileSecrets struct{}
+
+// Secret reads the secret at ref, relative to the working directory.
+func (fileSecrets) Secret(ref string) (string, error) {
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// secretIn reads the secret at ref, relative to dir.
+func (f fileSecrets) secretIn(dir, ref string) (string, error) {
+	if !filepath.IsAbs(ref) {
+		ref = filepath.Join(dir, ref)
+	}
+	return f.Secret(ref)
+}
+
+// relativeSecretProvider is implemented by secret providers whose refs are
+// paths, which are resolved against the directory of the configuration file
+// that references them rather than the working directory.
+type relativeSecretProvider interface {
+	secretIn(dir, ref string) (string, error)
+}
+
+// secretFunction returns the implementation of secret(provider, ref) for a
+// configuration file in dir. The result is marked sensitive so it is redacted
+// wherever the configuration is shown.
+func secretFunction(dir string) function.Function {
+	return function.New(&function.Spec{
+		Description: "Reads a secret from a secret provider such as \"env\" or \"file\". File paths are relative to the configuration file.",
+		Params: []function.Parameter{
+			{Name: "provider", Type: cty.String},
+			{Name: "ref", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			name := args[0].AsString()
+			provider, names := lookupSecretProvider(name)
+			if provider == nil {
+				if suggestion := nameSuggestion(name, names); suggestion != "" {
+					return cty.DynamicVal, function.NewArgErrorf(0, "unknown secret provider %q (did you mean %q?)", name, suggestion)
+				}
+				return cty.DynamicVal, function.NewArgErrorf(0, "unknown secret provider %q", name)
+			}
+
+			var secret string
+			var err error
+			if relative, ok := provider.(relativeSecretProvider); ok {
+				secret, err = relative.secretIn(dir, args[1].AsString())
+			} else {
+				secret, err = provider.Secret(args[1].AsString())
+			}
+			if err != nil {
+				return cty.DynamicVal, function.NewArgErrorf(1, "%s secret provider: %s", name, err)
+			}
+			return cty.StringVal(secret).Mark(sensitiveMark), nil
+		},
+	})
+}
@@ -2,9 +2,9 @@ package config
 
 import (
 	"fmt"
-	"net"
-
 	"os"
+	"path/filepath"
+	"reflect"
 
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/zclconf/go-cty/cty"
@@ -22,82 +22,75 @@ const (
 
 // Config holds the configuration for the server
 type Config struct {
-	Server ServerConfig `json:"server"`
+	Server ServerConfig `json:"server" hcl:"server,block" doc:"Settings for the gRPC server."`
 }
 
 type ServerConfig struct {
-	ListeningAddress string `json:"listening_address"`
+	ListeningAddress  string `json:"listening_address" hcl:"listening_address,attr" validate:"hostport" doc:"The address the gRPC server listens on."`
+	MetricsAddress    string `json:"metrics_address,omitempty" hcl:"metrics_address,optional" validate:"hostport" doc:"The address metrics are served on. It must differ from listening_address."`
+	TLSCertFile       string `json:"tls_cert_file,omitempty" hcl:"tls_cert_file,optional" doc:"The certificate the server presents for TLS. Requires tls_key_file."`
+	TLSKeyFile        string `json:"tls_key_file,omitempty" hcl:"tls_key_file,optional" doc:"The private key of tls_cert_file."`
+	ClientCAFile      string `json:"client_ca_file,omitempty" hcl:"client_ca_file,optional" doc:"The certificate authorities that client certificates are verified against. Requires TLS."`
+	RequireClientCert bool   `json:"require_client_cert,omitempty" hcl:"require_client_cert,optional" doc:"Whether clients must present a certificate signed by client_ca_file."`
 }
 
 func ParseConfigFile(filename string) (*Config, error) {
-	data, err := os.ReadFile(filename)
+	effective, err := EvaluateFile(filename)
 	if err != nil {
-		return nil, fmt.Errorf("os.ReadFile(%q): %w", filename, err)
+		return nil, err
 	}
-
-	return ParseConfig(filename, data)
+	return effective.Config, nil
 }
 
 func ParseConfig(filename string, src []byte) (*Config, hcl.Diagnostics) {
+	effective, diags := Evaluate(filename, src)
+	if effective == nil {
+		return nil, diags
+	}
+	return effective.Config, diags
+}
 
-	beginning := hcl.Pos{Line: 1, Column: 1}
+// EvaluateFile reads and evaluates the configuration file at filename. See
+// Evaluate.
+func EvaluateFile(filename string) (*Effective, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile(%q): %w", filename, err)
+	}
 
-	// ParseServerConfig parses and validates the server configuration from an HCL file
-	file, diags := hclsyntax.ParseConfig(src, filename, beginning)
+	effective, diags := Evaluate(filename, data)
 	if diags.HasErrors() {
 		return nil, diags
 	}
+	return effective, nil
+}
 
-	schema := &hcl.BodySchema{
-		Blocks: []hcl.BlockHeaderSchema{
-			{
-				Type:       "server",
-				LabelNames: []string{},
-			},
-		},
-	}
+// Evaluate parses and decodes a configuration like ParseConfig, and also
+// records where each value of the resulting Config came from.
+func Evaluate(filename string, src []byte) (*Effective, hcl.Diagnostics) {
+	beginning := hcl.Pos{Line: 1, Column: 1}
 
-	content, diags := file.Body.Content(schema)
+	file, diags := hclsyntax.ParseConfig(src, filename, beginning)
 	if diags.HasErrors() {
 		return nil, diags
 	}
 
 	var config Config
-	if len(content.Blocks.OfType("server")) != 1 {
-		diags = diags.Append(&hcl.Diagnostic{
-			Severity: hcl.DiagError,
-			Summary:  "Expected exactly one server block",
-			Detail:   "You provided a non-one number of server blocks.",
-		})
+	d := newDecoder(evalContext(filename))
+	diags = diags.Extend(d.decodeBody(file.Body, configSchema, nil, reflect.ValueOf(&config).Elem()))
+	effective := &Effective{Config: &config, sources: d.sources, sensitive: d.sensitive}
+	if !diags.HasErrors() {
+		diags = diags.Extend(runCrossFieldChecks(effective))
 	}
-	for _, block := range content.Blocks.OfType("server") {
-		sc, newDiags := parseServerConfig(block)
-		diags = diags.Extend(newDiags)
-		config.Server = sc
-	}
-
-	return &config, diags
+	return effective, diags
 }
 
-func parseServerConfig(block *hcl.Block) (ServerConfig, hcl.Diagnostics) {
-	var sc ServerConfig
-
-	schema := &hcl.BodySchema{
-		Attributes: []hcl.AttributeSchema{
-			{
-				Name:     "listening_address",
-				Required: true,
-			},
-		},
-	}
-	content, diags := block.Body.Content(schema)
-	if diags.HasErrors() {
-		return ServerConfig{}, diags
-	}
-
-	listeningAddress := content.Attributes["listening_address"]
-	listeningAddressValue, listeningDiags := listeningAddress.Expr.Value(&hcl.EvalContext{
+// evalContext returns the context that expressions in the configuration file
+// filename are evaluated in.
+func evalContext(filename string) *hcl.EvalContext {
+	return &hcl.EvalContext{
 		Functions: map[string]function.Function{
+			"secret": secretFunction(filepath.Dir(filename)),
 			"helloworld::with::more::things": function.New(&function.Spec{
 				Description: "hello world function",
 				Type:        function.StaticReturnType(cty.String),
@@ -106,22 +99,5 @@ func parseServerConfig(block *hcl.Block) (ServerConfig, hcl.Diagnostics) {
 				},
 			}),
 		},
-	})
-	if diags.HasErrors() {
-		diags = diags.Extend(listeningDiags)
-	} else {
-		address := listeningAddressValue.AsString()
-		host, port, err := net.SplitHostPort(address)
-		if err != nil || host == "" || port == "" {
-			diags = diags.Append(&hcl.Diagnostic{
-				Severity: hcl.DiagError,
-				Summary:  "Invalid listening address",
-				Detail:   "The 'listening_address' must be in the format 'host:port'.",
-				Subject:  listeningAddress.Expr.Range().Ptr(),
-			})
-		}
-		sc.ListeningAddress = address
 	}
-
-	return sc, diags
 }
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/achew22/toy-project/internal/goldentest"
@@ -21,7 +22,11 @@ func TestParseConfig(t *testing.T) {
 		},
 		ErrorFunc: func(err error) []byte {
 			if diags, ok := err.(hcl.Diagnostics); ok && diags.HasErrors() {
-				return []byte(diags.Error())
+				lines := make([]string, 0, len(diags))
+				for _, diag := range diags {
+					lines = append(lines, diag.Error())
+				}
+				return []byte(strings.Join(lines, "\n"))
 			}
 			return []byte(err.Error())
 		},
@@ -29,3 +34,30 @@ func TestParseConfig(t *testing.T) {
 
 	config.RunTests(t, "testdata")
 }
+
+func BenchmarkParseConfig(b *testing.B) {
+	// Benchmarks do not compare outputs, so errors need no formatting.
+	config := &goldentest.TestConfig[*Config, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		TestOneShotFunc: func(_ struct{}, filePath string, data []byte) (*Config, error) {
+			config, diags := ParseConfig(filePath, data)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			return config, nil
+		},
+	}
+
+	config.RunBenchmarks(b, "testdata")
+}
+
+func FuzzParseConfig(f *testing.F) {
+	goldentest.AddSeeds(f, "testdata", ".hcl")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		config, diags := ParseConfig("fuzz.hcl", data)
+		if config == nil && !diags.HasErrors() {
+			t.Errorf("ParseConfig returned neither a config nor errors for:\n%s", data)
+		}
+	})
+}
@@ -0,0 +1,3 @@
+server {
+  listening_address = helloworld::with::more::things()
+}
@@ -0,0 +1,3 @@
+server {
+  listening_address = "${secret("fake", "host")}:8443"
+}
@@ -0,0 +1,7 @@
+variable "address" {
+  default = secret("file", "listening_address.secret")
+}
+
+server {
+  listening_address = var.address
+}
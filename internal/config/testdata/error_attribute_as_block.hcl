@@ -0,0 +1,4 @@
+server {
+  listening_address {
+  }
+}
@@ -0,0 +1,4 @@
+server {
+  listening_address = "0.0.0.0:8080"
+  client_ca_file    = "clients.crt"
+}
@@ -0,0 +1,11 @@
+variable "port" {
+  default = 8080
+}
+
+variable "port" {
+  default = 9090
+}
+
+server {
+  listening_address = "127.0.0.1:${var.port}"
+}
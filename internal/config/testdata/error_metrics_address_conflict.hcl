@@ -0,0 +1,4 @@
+server {
+  listening_address = "0.0.0.0:8080"
+  metrics_address   = "127.0.0.1:8080"
+}
@@ -0,0 +1,3 @@
+server {
+  listening_address = secret("env", "TOY_PROJECT_UNSET_VARIABLE")
+}
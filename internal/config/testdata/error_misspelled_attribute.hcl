@@ -0,0 +1,3 @@
+server {
+  listening_adress = "0.0.0.0:8080"
+}
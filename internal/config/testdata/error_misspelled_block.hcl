@@ -0,0 +1,3 @@
+servr {
+  listening_address = "0.0.0.0:8080"
+}
@@ -0,0 +1,8 @@
+server {
+  listening_address = "127.0.0.1:8080"
+
+  validation {
+    condition     = self.listenin_address != ""
+    error_message = "The listening address must be set."
+  }
+}
@@ -0,0 +1,7 @@
+variable "port" {
+  default = 8080
+}
+
+server {
+  listening_address = "127.0.0.1:${var.prot}"
+}
@@ -0,0 +1,6 @@
+server {
+  listening_address   = "0.0.0.0:8443"
+  tls_cert_file       = "server.crt"
+  tls_key_file        = "server.key"
+  require_client_cert = true
+}
@@ -0,0 +1,4 @@
+server {
+  listening_address = "0.0.0.0:8443"
+  tls_key_file      = "server.key"
+}
@@ -0,0 +1,4 @@
+server {
+  listening_address = "0.0.0.0:8080"
+  listening_address_v6 = "[::1]:8080"
+}
@@ -0,0 +1,3 @@
+server {
+  listening_address = secret("evn", "TOY_PROJECT_LISTENING_ADDRESS")
+}
@@ -0,0 +1,8 @@
+server {
+  listening_address = secret("file", "effective/listening_address.secret")
+
+  validation {
+    condition     = self.listening_address == "127.0.0.1:8443"
+    error_message = "Unexpected address ${self.listening_address}."
+  }
+}
@@ -0,0 +1,8 @@
+server {
+  listening_address = "127.0.0.1:80"
+}
+
+validation {
+  condition     = self.server.listening_address != "127.0.0.1:80"
+  error_message = "Port 80 is reserved for the proxy."
+}
@@ -0,0 +1,12 @@
+variable "port" {
+  default = 80
+
+  validation {
+    condition     = self >= 1024
+    error_message = "The port must not be privileged."
+  }
+}
+
+server {
+  listening_address = "127.0.0.1:${var.port}"
+}
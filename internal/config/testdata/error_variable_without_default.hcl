@@ -0,0 +1,6 @@
+variable "port" {
+}
+
+server {
+  listening_address = "127.0.0.1:8080"
+}
@@ -0,0 +1,8 @@
+server {
+  listening_address   = "0.0.0.0:8443"
+  metrics_address     = "127.0.0.1:9090"
+  tls_cert_file       = "server.crt"
+  tls_key_file        = "server.key"
+  client_ca_file      = "clients.crt"
+  require_client_cert = true
+}
@@ -0,0 +1,8 @@
+server {
+  listening_address = "127.0.0.1:8080"
+
+  validation {
+    condition     = self.listening_address != "0.0.0.0:8080"
+    error_message = "The server must not listen on every interface."
+  }
+}
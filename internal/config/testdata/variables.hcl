@@ -0,0 +1,17 @@
+variable "host" {
+  default = "127.0.0.1"
+}
+
+variable "port" {
+  default = 8080
+
+  validation {
+    condition     = self >= 1024
+    error_message = "The port must not be privileged."
+  }
+}
+
+server {
+  listening_address = "${var.host}:${var.port}"
+  metrics_address   = "${var.host}:${var.port + 1}"
+}
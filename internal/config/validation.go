@@ -0,0 +1,240 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/gocty"
+
+	hcl "github.com/hashicorp/hcl/v2"
+)
+
+// validationBlockType is the type of the blocks that attach custom checks to
+// any block of the configuration, including the top level of a file:
+//
+//	server {
+//	  listening_address = "0.0.0.0:8080"
+//
+//	  validation {
+//	    condition     = self.listening_address != "0.0.0.0:8080"
+//	    error_message = "The server must not listen on every interface."
+//	  }
+//	}
+//
+// The condition is evaluated after the enclosing block has been decoded, with
+// self referring to the block's attributes and nested blocks. Variables may
+// contain validation blocks too; see variableBlockType.
+const validationBlockType = "validation"
+
+var validationSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "condition", Required: true},
+		{Name: "error_message", Required: true},
+	},
+}
+
+// crossFieldCheck checks a relationship between several values of a decoded
+// configuration that cannot be expressed as a validator on a single field.
+// Diagnostics should use Effective.subject so they point at the values that
+// are in conflict.
+type crossFieldCheck func(effective *Effective) hcl.Diagnostics
+
+// crossFieldChecks are run on every configuration that decodes without
+// errors, in order.
+var crossFieldChecks = []crossFieldCheck{
+	checkTLSKeyPair,
+	checkClientCA,
+	checkMetricsAddress,
+}
+
+// checkTLSKeyPair checks that the TLS certificate and its key are set
+// together, since neither is usable alone.
+func checkTLSKeyPair(effective *Effective) hcl.Diagnostics {
+	server := effective.Config.Server
+	if (server.TLSCertFile == "") == (server.TLSKeyFile == "") {
+		return nil
+	}
+	set, missing := "server.tls_cert_file", "tls_key_file"
+	if server.TLSCertFile == "" {
+		set, missing = "server.tls_key_file", "tls_cert_file"
+	}
+	return hcl.Diagnostics{{
+		Severity: hcl.DiagError,
+		Summary:  "Incomplete TLS key pair",
+		Detail:   fmt.Sprintf("The '%s' must be set too.", missing),
+		Subject:  effective.subject(set),
+	}}
+}
+
+// checkClientCA checks that client certificates can be verified: they are
+// only sent over TLS, and are only required if there is a CA to verify them.
+func checkClientCA(effective *Effective) hcl.Diagnostics {
+	server := effective.Config.Server
+	var diags hcl.Diagnostics
+	if server.ClientCAFile != "" && server.TLSCertFile == "" {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Client CA without TLS",
+			Detail:   "Client certificates are only verified over TLS, so 'tls_cert_file' and 'tls_key_file' must be set.",
+			Subject:  effective.subject("server.client_ca_file"),
+		})
+	}
+	if server.RequireClientCert && server.ClientCAFile == "" {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing client CA",
+			Detail:   "Requiring client certificates needs a 'client_ca_file' to verify them against.",
+			Subject:  effective.subject("server.require_client_cert"),
+		})
+	}
+	return diags
+}
+
+// checkMetricsAddress checks that metrics are not served on the port the
+// gRPC server listens on. Both addresses are valid host:port pairs, since
+// validators already ran.
+func checkMetricsAddress(effective *Effective) hcl.Diagnostics {
+	server := effective.Config.Server
+	if server.MetricsAddress == "" || !addressesConflict(server.MetricsAddress, server.ListeningAddress) {
+		return nil
+	}
+	return hcl.Diagnostics{{
+		Severity: hcl.DiagError,
+		Summary:  "Conflicting metrics address",
+		Detail:   "The 'metrics_address' must differ from the 'listening_address'.",
+		Subject:  effective.subject("server.metrics_address"),
+	}}
+}
+
+// addressesConflict reports whether listening on both host:port addresses
+// would bind the same port of the same interface. An unspecified host, such
+// as 0.0.0.0, conflicts with every host.
+func addressesConflict(a, b string) bool {
+	hostA, portA, _ := net.SplitHostPort(a)
+	hostB, portB, _ := net.SplitHostPort(b)
+	if portA != portB {
+		return false
+	}
+	return hostA == hostB || unspecifiedHost(hostA) || unspecifiedHost(hostB)
+}
+
+// unspecifiedHost reports whether host listens on every interface.
+func unspecifiedHost(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
+}
+
+// runCrossFieldChecks runs crossFieldChecks against effective.
+func runCrossFieldChecks(effective *Effective) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, check := range crossFieldChecks {
+		diags = diags.Extend(check(effective))
+	}
+	return diags
+}
+
+// subject returns the range of the expression that set the attribute at the
+// dotted path, or nil if it was not set in the configuration.
+func (e *Effective) subject(path string) *hcl.Range {
+	if source, ok := e.sources[path]; ok {
+		return &source
+	}
+	return nil
+}
+
+// checkValidations evaluates the validation blocks of a decoded block. self
+// is the value of the block, as returned by selfValue.
+func (d *decoder) checkValidations(blocks hcl.Blocks, self cty.Value) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	if len(blocks) == 0 {
+		return diags
+	}
+
+	ctx := d.ctx.NewChild()
+	ctx.Variables = map[string]cty.Value{"self": self}
+
+	for _, block := range blocks {
+		content, contentDiags := bodyContent(block.Body, validationSchema)
+		diags = diags.Extend(contentDiags)
+		if contentDiags.HasErrors() {
+			continue
+		}
+
+		condition := content.Attributes["condition"]
+		result, conditionDiags := condition.Expr.Value(ctx)
+		diags = diags.Extend(suggestAttributes(suggestFunctions(conditionDiags, ctx)))
+		if conditionDiags.HasErrors() {
+			continue
+		}
+		result, _ = result.Unmark()
+		result, err := convert.Convert(result, cty.Bool)
+		if err != nil || result.IsNull() || !result.IsKnown() {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid validation condition",
+				Detail:   "The condition must evaluate to true or false.",
+				Subject:  condition.Expr.Range().Ptr(),
+			})
+			continue
+		}
+		if result.True() {
+			continue
+		}
+
+		errorMessage := content.Attributes["error_message"]
+		message, messageDiags := errorMessage.Expr.Value(ctx)
+		diags = diags.Extend(suggestAttributes(suggestFunctions(messageDiags, ctx)))
+		if messageDiags.HasErrors() {
+			continue
+		}
+		detail := "The validation condition is not satisfied."
+		if message.IsMarked() {
+			detail = "The error message refers to sensitive values, so it cannot be shown."
+		} else if message, err := convert.Convert(message, cty.String); err == nil && !message.IsNull() {
+			detail = message.AsString()
+		}
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Validation failed",
+			Detail:   detail,
+			Subject:  condition.Expr.Range().Ptr(),
+		})
+	}
+	return diags
+}
+
+// selfValue returns the value of a decoded block as a cty object, so that it
+// can be referred to as self in validation conditions. Sensitive attributes
+// keep their mark.
+func (d *decoder) selfValue(schema *blockSchema, path []string, target reflect.Value) cty.Value {
+	attrs := map[string]cty.Value{}
+	for _, field := range schema.Fields {
+		fieldPath := append(path[:len(path):len(path)], field.Name)
+		fieldValue := target.Field(field.Index)
+		switch field.Kind {
+		case attributeKind:
+			value := cty.DynamicVal
+			if ty, err := gocty.ImpliedType(fieldValue.Interface()); err == nil {
+				if v, err := gocty.ToCtyValue(fieldValue.Interface(), ty); err == nil {
+					value = v
+				}
+			}
+			if field.Sensitive || d.sensitive[strings.Join(fieldPath, ".")] {
+				value = value.Mark(sensitiveMark)
+			}
+			attrs[field.Name] = value
+		case blockKind:
+			attrs[field.Name] = d.selfValue(field.Block, fieldPath, fieldValue)
+		}
+	}
+	return cty.ObjectVal(attrs)
+}
+
+// validationReference documents validation blocks for the reference.
+var validationReference = fmt.Sprintf("Every block, including the top level of a file, may contain `%s` blocks "+
+	"with a boolean `condition` and an `error_message` that is reported when the condition is false. "+
+	"In the condition, `self` refers to the enclosing block.", validationBlockType)
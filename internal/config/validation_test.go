@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+
+	hcl "github.com/hashicorp/hcl/v2"
+)
+
+func TestCrossFieldChecks(t *testing.T) {
+	saved := crossFieldChecks
+	t.Cleanup(func() { crossFieldChecks = saved })
+	crossFieldChecks = []crossFieldCheck{
+		func(effective *Effective) hcl.Diagnostics {
+			if effective.Config.Server.ListeningAddress != "127.0.0.1:9090" {
+				return nil
+			}
+			return hcl.Diagnostics{{
+				Severity: hcl.DiagError,
+				Summary:  "Conflicting addresses",
+				Subject:  effective.subject("server.listening_address"),
+			}}
+		},
+	}
+
+	_, diags := Evaluate("test.hcl", []byte(`server {
+  listening_address = "127.0.0.1:8080"
+}
+`))
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	_, diags = Evaluate("test.hcl", []byte(`server {
+  listening_address = "127.0.0.1:9090"
+}
+`))
+	if len(diags) != 1 {
+		t.Fatalf("expected one diagnostic, got: %v", diags)
+	}
+	if got, want := diags[0].Subject.String(), "test.hcl:2,23-39"; got != want {
+		t.Errorf("diagnostic subject = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
+	hcl "github.com/hashicorp/hcl/v2"
+)
+
+// variableBlockType is the type of the top-level blocks that declare named
+// values, which the rest of the file refers to as var.<name>:
+//
+//	variable "port" {
+//	  default = 8080
+//
+//	  validation {
+//	    condition     = self >= 1024
+//	    error_message = "The port must not be privileged."
+//	  }
+//	}
+//
+//	server {
+//	  listening_address = "127.0.0.1:${var.port}"
+//	}
+//
+// A variable's value is its default, which may call functions but not refer
+// to other variables. Its validation blocks are evaluated with self referring
+// to the value, before the rest of the file is decoded.
+const variableBlockType = "variable"
+
+// variableNamespace is the name that variables are referred to through.
+const variableNamespace = "var"
+
+var variableSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "default", Required: true},
+	},
+	Blocks: []hcl.BlockHeaderSchema{{Type: validationBlockType}},
+}
+
+// decodeVariables evaluates the variable blocks of a file and makes their
+// values available to the expressions decoded after them as var.<name>.
+func (d *decoder) decodeVariables(blocks hcl.Blocks) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	values := map[string]cty.Value{}
+	declared := map[string]*hcl.Block{}
+	for _, block := range blocks {
+		name := block.Labels[0]
+		if !hclsyntax.ValidIdentifier(name) {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid variable name",
+				Detail:   "A name must start with a letter or underscore and may contain only letters, digits, underscores, and dashes.",
+				Subject:  block.LabelRanges[0].Ptr(),
+			})
+			continue
+		}
+		if previous, ok := declared[name]; ok {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate variable",
+				Detail:   fmt.Sprintf("A variable named %q was already declared at %s.", name, previous.DefRange),
+				Subject:  block.LabelRanges[0].Ptr(),
+			})
+			continue
+		}
+		declared[name] = block
+
+		content, contentDiags := bodyContent(block.Body, variableSchema)
+		diags = diags.Extend(contentDiags)
+		if contentDiags.HasErrors() {
+			continue
+		}
+		value, valueDiags := content.Attributes["default"].Expr.Value(d.ctx)
+		diags = diags.Extend(suggestFunctions(valueDiags, d.ctx))
+		if valueDiags.HasErrors() {
+			continue
+		}
+		values[name] = value
+		diags = diags.Extend(d.checkValidations(content.Blocks.OfType(validationBlockType), value))
+	}
+
+	// Without variables, var is still an object so that a reference to an
+	// undeclared variable is reported as such.
+	d.ctx.Variables = map[string]cty.Value{variableNamespace: cty.ObjectVal(values)}
+	return diags
+}
+
+// variableReference documents variable blocks for the reference.
+var variableReference = fmt.Sprintf("The top level of a file may contain `%s \"<name>\"` blocks with a `default` value, "+
+	"which the rest of the file refers to as `%s.<name>`. They may contain `%s` blocks too, in whose condition "+
+	"`self` refers to the value of the variable.", variableBlockType, variableNamespace, validationBlockType)
@@ -0,0 +1,203 @@
+package goldentest
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+)
+
+// RunBenchmarks benchmarks the test function of config with the test cases in
+// dir, so that the inputs written for golden tests double as benchmarks. Each
+// test case is a sub-benchmark named like its test, which reports ns/op and
+// allocations for one run of the case:
+//
+//   - One-shot: a call to TestOneShotFunc with the input file.
+//   - Step: a call to StepTestFunc for every step in order, with captured
+//     values expanded. Each run gets a fresh fixture, created with the timer
+//     stopped, since steps usually change the state of the fixture.
+//
+// Fixtures are created by BenchSetUp and cleaned up by BenchTearDown, which
+// must be set if SetUp or SetUpSuite is.
+//
+// Results and errors are not compared against golden files, and the case
+// metadata is honored except for timeouts. Parallel is ignored: the cases run
+// one after the other.
+//
+// Example:
+//
+//	func BenchmarkParseConfig(b *testing.B) {
+//		config.RunBenchmarks(b, "testdata")
+//	}
+func (config *TestConfig[T, F]) RunBenchmarks(b *testing.B, dir string) {
+	config.RunBenchmarksFS(b, DirFS(dir), ".")
+}
+
+// RunBenchmarksFS is like RunBenchmarks, but reads the test files from dir in
+// fsys.
+func (config *TestConfig[T, F]) RunBenchmarksFS(b *testing.B, fsys fs.FS, dir string) {
+	config.validate(b)
+	if config.BenchSetUp == nil && (config.SetUp != nil || config.SetUpSuite != nil) {
+		b.Fatal("TestConfig SetUp or SetUpSuite is set but BenchSetUp is not - benchmarks create their fixtures with BenchSetUp")
+	}
+
+	r := &runner[T, F]{config: config}
+
+	if config.TestOneShotFunc != nil {
+		r.benchOneShot(b, fsys, dir)
+	} else {
+		r.benchSteps(b, fsys, dir)
+	}
+}
+
+// benchCase runs fn as a sub-benchmark called name with the metadata returned
+// by load. The sub-benchmark is skipped if its metadata says so.
+func benchCase(b *testing.B, name string, load func() (*CaseMetadata, error), fn func(b *testing.B)) {
+	b.Run(name, func(b *testing.B) {
+		meta, err := load()
+		if err != nil {
+			b.Fatalf("failed to load test case %s: %v", name, err)
+		}
+		if reason := meta.skipReason(); reason != "" {
+			b.Skip(reason)
+		}
+		// BenchSetUp reads the metadata with Metadata
+		setMetadata(b, meta)
+		b.ReportAllocs()
+		fn(b)
+	})
+}
+
+// benchOneShot benchmarks TestOneShotFunc with every input in dir of fsys.
+func (r *runner[T, F]) benchOneShot(b *testing.B, fsys fs.FS, dir string) {
+	config := r.config
+	caseFiles := dirFiles{fsys: fsys, dir: dir}
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		b.Fatalf("failed to read testdata directory: %v", err)
+	}
+
+	for _, file := range files {
+		if !config.isOneShotInput(file) {
+			continue
+		}
+		metaFile := strings.TrimSuffix(file.Name(), config.InputExt) + MetadataExt
+		load := func() (*CaseMetadata, error) { return loadMetadata(caseFiles, metaFile) }
+		benchCase(b, file.Name(), load, func(b *testing.B) {
+			filePath := caseFiles.path(file.Name())
+			data, err := caseFiles.read(file.Name())
+			if err != nil {
+				b.Fatalf("failed to read file %s: %v", file.Name(), err)
+			}
+			fixture, release := r.benchFixture(b, file.Name())
+			defer release()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				config.TestOneShotFunc(fixture, filePath, data)
+			}
+			b.StopTimer()
+		})
+	}
+
+	if !config.Recursive {
+		return
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			group := path.Join(dir, file.Name())
+			b.Run(file.Name(), func(b *testing.B) { r.benchOneShot(b, fsys, group) })
+		}
+	}
+}
+
+// benchSteps benchmarks StepTestFunc with every step test case in dir of fsys.
+func (r *runner[T, F]) benchSteps(b *testing.B, fsys fs.FS, dir string) {
+	config := r.config
+	cases, err := config.stepCases(fsys, dir)
+	if err != nil {
+		b.Fatalf("failed to read testdata directory: %v", err)
+	}
+
+	for _, c := range cases {
+		if c.group != "" {
+			b.Run(c.name, func(b *testing.B) { r.benchSteps(b, fsys, c.group) })
+			continue
+		}
+
+		var files caseFiles
+		load := func() (*CaseMetadata, error) {
+			var err error
+			if files, err = c.open(); err != nil {
+				return nil, err
+			}
+			return loadMetadata(files, MetadataFile)
+		}
+		benchCase(b, c.name, load, func(b *testing.B) {
+			stepFiles, err := config.loadStepFiles(files)
+			if err != nil {
+				b.Fatalf("failed to validate test case %s: %v", c.name, err)
+			}
+			if err := config.validateCaptures(stepFiles); err != nil {
+				b.Fatalf("invalid captures in test case %s: %v", c.name, err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				fixture, release := r.benchFixture(b, c.name)
+				b.StartTimer()
+
+				r.replaySteps(b, fixture, stepFiles)
+
+				b.StopTimer()
+				release()
+				b.StartTimer()
+			}
+			b.StopTimer()
+		})
+	}
+}
+
+// benchFixture returns the fixture for a run of the benchmark of the test case
+// name, created by BenchSetUp, and a function that calls BenchTearDown once the
+// run is done with it. Without BenchSetUp, the fixture is the zero value of F.
+func (r *runner[T, F]) benchFixture(b *testing.B, name string) (F, func()) {
+	config := r.config
+	var fixture F
+	if config.BenchSetUp != nil {
+		var err error
+		fixture, err = config.BenchSetUp(b)
+		if err != nil {
+			b.Fatalf("BenchSetUp failed for %s: %v", name, err)
+		}
+	}
+	return fixture, func() {
+		if config.BenchTearDown != nil {
+			if err := config.BenchTearDown(b, fixture); err != nil {
+				b.Errorf("BenchTearDown failed for %s: %v", name, err)
+			}
+		}
+	}
+}
+
+// replaySteps calls StepTestFunc for each of stepFiles in order, expanding the
+// values captured from the results of earlier steps. A step whose references
+// cannot be expanded, because the step that captures them failed, is skipped.
+func (r *runner[T, F]) replaySteps(b *testing.B, fixture F, stepFiles []StepFile) {
+	config := r.config
+	captured := map[string]string{}
+	for _, stepFile := range stepFiles {
+		stepFile, err := expandCaptures(stepFile, captured)
+		if err != nil {
+			continue
+		}
+		result, err := config.StepTestFunc(b.Context(), fixture, stepFile)
+		if err != nil {
+			continue
+		}
+		if err := config.captureValues(captured, stepFile, result); err != nil {
+			b.Fatalf("step %d (%s): %v", stepFile.Step, path.Base(stepFile.FilePath), err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package goldentest
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// benchmark runs fn with testing.Benchmark for a few iterations, rather than
+// for the default -test.benchtime of a second.
+func benchmark(t *testing.T, fn func(b *testing.B)) testing.BenchmarkResult {
+	benchtime := flag.Lookup("test.benchtime")
+	old := benchtime.Value.String()
+	if err := benchtime.Value.Set("10x"); err != nil {
+		t.Fatalf("failed to set -test.benchtime: %v", err)
+	}
+	defer benchtime.Value.Set(old)
+	return testing.Benchmark(fn)
+}
+
+func TestRunBenchmarksSteps(t *testing.T) {
+	fsys := contents(map[string]string{
+		// Outputs are not compared, so a wrong output does not matter
+		"create/1.in.hcl":  "# capture id = id\ncreate",
+		"create/1.out.txt": "wrong",
+		"create/2.in.hcl":  "get ${capture.id}",
+		"create/3.in.hcl":  "fail",
+		"skipped/case.hcl": "skip = true",
+		"skipped/1.in.hcl": "create",
+	})
+
+	var steps []string
+	setUps, tearDowns := 0, 0
+	config := &TestConfig[string, int]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".txt",
+		Capture:          func(result, path string) (string, error) { return result, nil },
+		StepTestFunc: func(_ context.Context, fixture int, stepFile StepFile) (string, error) {
+			data := string(stepFile.Data)
+			steps = append(steps, data)
+			if data == "fail" {
+				return "", errors.New("failed")
+			}
+			return strings.Repeat("x", fixture), nil
+		},
+		BenchSetUp: func(b *testing.B) (int, error) {
+			setUps++
+			return setUps, nil
+		},
+		BenchTearDown: func(b *testing.B, fixture int) error {
+			tearDowns++
+			return nil
+		},
+	}
+
+	result := benchmark(t, func(b *testing.B) {
+		steps, setUps, tearDowns = nil, 0, 0
+		config.RunBenchmarksFS(b, fsys, ".")
+	})
+	if result.N == 0 {
+		t.Fatal("the benchmark did not run")
+	}
+
+	// Each run of the case gets its own fixture and replays every step
+	if setUps == 0 || tearDowns != setUps || len(steps) != 3*setUps {
+		t.Fatalf("got %d set-ups, %d tear-downs and %d steps, want one set-up and tear-down for every 3 steps", setUps, tearDowns, len(steps))
+	}
+	want := []string{"# capture id = id\ncreate", "get x", "fail", "# capture id = id\ncreate", "get xx", "fail"}
+	if diff := cmp.Diff(want, steps[:6]); diff != "" {
+		t.Errorf("steps mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunBenchmarksOneShot(t *testing.T) {
+	fsys := contents(map[string]string{
+		"a.hcl":            "a",
+		"skipped.hcl":      "skipped",
+		"skipped.meta.hcl": "skip = true",
+		"nested/b.hcl":     "b",
+	})
+
+	calls := map[string]int{}
+	config := NewOneShotConfig(func(_ struct{}, filePath string, data []byte) (string, error) {
+		calls[filePath]++
+		return string(data), nil
+	}).WithInputExt(".hcl").WithSuccessExt(".txt").WithRecursive().Build()
+
+	benchmark(t, func(b *testing.B) {
+		config.RunBenchmarksFS(b, fsys, ".")
+	})
+	if calls["a.hcl"] == 0 || calls["nested/b.hcl"] == 0 {
+		t.Errorf("got calls %v, want a.hcl and nested/b.hcl to be benchmarked", calls)
+	}
+	if calls["skipped.hcl"] != 0 {
+		t.Errorf("skipped.hcl was called %d times, want it to be skipped", calls["skipped.hcl"])
+	}
+}
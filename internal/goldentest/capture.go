@@ -0,0 +1,258 @@
+package goldentest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// CaptureFunc returns the value at path in the result of a step, as declared
+// by a capture directive in the step's input file.
+//
+// Example:
+//
+//	config.Capture = func(result *Response, path string) (string, error) {
+//		if path != "id" {
+//			return "", fmt.Errorf("unknown field %q", path)
+//		}
+//		return result.ID, nil
+//	}
+type CaptureFunc[T any] func(result T, path string) (string, error)
+
+// Capture is a value that a step captures from its result for later steps.
+type Capture struct {
+	// Name is the name later steps reference the value by, as ${capture.Name}.
+	Name string
+	// Path is the location of the value in the step's result, interpreted by
+	// the config's CaptureFunc.
+	Path string
+}
+
+var (
+	// captureDirective matches a capture directive line in a step input,
+	// e.g. "# capture user_id = rpc.create_user_response.user.name".
+	captureDirective = regexp.MustCompile(`(?m)^[ \t]*#[ \t]*capture[ \t]+(\w+)[ \t]*=[ \t]*(\S+)[ \t]*$`)
+	// captureReference matches a reference to a captured value in a step input.
+	captureReference = regexp.MustCompile(`\$\{capture\.(\w+)\}`)
+)
+
+// parseCaptures returns the captures declared by the directives in data.
+func parseCaptures(data []byte) []Capture {
+	var captures []Capture
+	for _, match := range captureDirective.FindAllSubmatch(data, -1) {
+		captures = append(captures, Capture{Name: string(match[1]), Path: string(match[2])})
+	}
+	return captures
+}
+
+// validateCaptures checks that every step only references values captured by
+// the steps before it, so that a typo fails the test case before any step
+// runs.
+func (config *TestConfig[T, F]) validateCaptures(stepFiles []StepFile) error {
+	declaredBy := map[string]int{}
+	for _, stepFile := range stepFiles {
+		for _, match := range captureReference.FindAllSubmatch(stepFile.Data, -1) {
+			name := string(match[1])
+			if _, ok := declaredBy[name]; !ok {
+				return fmt.Errorf("step %d references ${capture.%s}, which no earlier step captures", stepFile.Step, name)
+			}
+		}
+		for _, capture := range parseCaptures(stepFile.Data) {
+			if config.Capture == nil {
+				return fmt.Errorf("step %d captures %s, but Capture is not set", stepFile.Step, capture.Name)
+			}
+			if step, ok := declaredBy[capture.Name]; ok {
+				return fmt.Errorf("step %d captures %s, which step %d already captures", stepFile.Step, capture.Name, step)
+			}
+			declaredBy[capture.Name] = stepFile.Step
+		}
+	}
+	return nil
+}
+
+// captureValues records the values that stepFile declares from result.
+func (config *TestConfig[T, F]) captureValues(captured map[string]string, stepFile StepFile, result T) error {
+	for _, capture := range parseCaptures(stepFile.Data) {
+		value, err := config.Capture(result, capture.Path)
+		if err != nil {
+			return fmt.Errorf("failed to capture %s from %s: %w", capture.Name, capture.Path, err)
+		}
+		captured[capture.Name] = value
+	}
+	return nil
+}
+
+// expandCaptures returns stepFile with every ${capture.name} in its data
+// replaced by the captured value, escaped by escapeCapture so that a reference
+// inside a double-quoted string yields that value.
+func expandCaptures(stepFile StepFile, captured map[string]string) (StepFile, error) {
+	var missing []string
+	var escapeErr error
+	stepFile.Data = captureReference.ReplaceAllFunc(stepFile.Data, func(match []byte) []byte {
+		name := string(captureReference.FindSubmatch(match)[1])
+		value, ok := captured[name]
+		if !ok {
+			missing = append(missing, name)
+			return match
+		}
+		escaped, err := escapeCapture(value)
+		if err != nil && escapeErr == nil {
+			escapeErr = fmt.Errorf("captured value of %s: %w", name, err)
+		}
+		return []byte(escaped)
+	})
+	if len(missing) > 0 {
+		// Only values declared by earlier steps pass validateCaptures, so a
+		// value is missing when the step that declares it failed.
+		return stepFile, fmt.Errorf("no value was captured for %s because the step that captures it failed", strings.Join(missing, ", "))
+	}
+	return stepFile, escapeErr
+}
+
+// captureEscapes escapes the characters that end or break a double-quoted
+// string, the same way in HCL, JSON and protobuf text.
+var captureEscapes = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// escapeCapture returns value escaped for a double-quoted string in a step
+// input. Values without quotes, backslashes or control characters, such as
+// names and numbers, are unchanged, so they may be referenced outside strings
+// too. Other control characters have no escape common to every input format,
+// so values with them are rejected.
+func escapeCapture(value string) (string, error) {
+	for _, r := range value {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return "", fmt.Errorf("%q contains a control character that cannot be escaped in a step input", value)
+		}
+	}
+	return captureEscapes.Replace(value), nil
+}
+
+// CaptureProtoField returns a CaptureFunc that resolves paths of field names
+// separated by dots, e.g. "rpc.create_user_response.user.name". Elements of
+// repeated fields are selected by index and map values by key, e.g.
+// "users.0.labels.team". Unset fields resolve to their default value.
+func CaptureProtoField[T proto.Message]() CaptureFunc[T] {
+	return func(result T, path string) (string, error) {
+		value := protoreflect.ValueOfMessage(result.ProtoReflect())
+		var fd protoreflect.FieldDescriptor
+		var resolved []string
+		for _, elem := range strings.Split(path, ".") {
+			var err error
+			value, fd, err = protoPathElement(value, fd, elem)
+			if err != nil {
+				if len(resolved) > 0 {
+					return "", fmt.Errorf("%s: %w", strings.Join(resolved, "."), err)
+				}
+				return "", err
+			}
+			resolved = append(resolved, elem)
+		}
+		return protoScalarString(value, fd)
+	}
+}
+
+// protoPathElement resolves elem against value, the value of field fd (nil for
+// the root message), and returns the resolved value and its field. An element
+// of a list or map is described by its list or map field.
+func protoPathElement(value protoreflect.Value, fd protoreflect.FieldDescriptor, elem string) (protoreflect.Value, protoreflect.FieldDescriptor, error) {
+	switch {
+	case fd != nil && fd.IsList() && isCollection(value, fd):
+		index, err := strconv.Atoi(elem)
+		list := value.List()
+		if err != nil || index < 0 || index >= list.Len() {
+			return value, fd, fmt.Errorf("%q is not an index of a list of %d elements", elem, list.Len())
+		}
+		return list.Get(index), fd, nil
+	case fd != nil && fd.IsMap() && isCollection(value, fd):
+		key, err := protoMapKey(fd.MapKey(), elem)
+		if err != nil {
+			return value, fd, err
+		}
+		if !value.Map().Has(key) {
+			return value, fd, fmt.Errorf("map has no key %q", elem)
+		}
+		return value.Map().Get(key), fd.MapValue(), nil
+	case fd == nil || fd.Message() != nil:
+		m := value.Message()
+		field := m.Descriptor().Fields().ByName(protoreflect.Name(elem))
+		if field == nil {
+			return value, fd, fmt.Errorf("%s has no field %q", m.Descriptor().FullName(), elem)
+		}
+		return m.Get(field), field, nil
+	default:
+		return value, fd, fmt.Errorf("cannot select %q from a %s", elem, fd.Kind())
+	}
+}
+
+// isCollection reports whether value is the list or map of fd itself, rather
+// than one of its elements.
+func isCollection(value protoreflect.Value, fd protoreflect.FieldDescriptor) bool {
+	switch value.Interface().(type) {
+	case protoreflect.List:
+		return fd.IsList()
+	case protoreflect.Map:
+		return fd.IsMap()
+	}
+	return false
+}
+
+// protoMapKey parses s as a map key of the kind described by fd.
+func protoMapKey(fd protoreflect.FieldDescriptor, s string) (protoreflect.MapKey, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s).MapKey(), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return protoreflect.MapKey{}, fmt.Errorf("%q is not a bool map key", s)
+		}
+		return protoreflect.ValueOfBool(b).MapKey(), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return protoreflect.MapKey{}, fmt.Errorf("%q is not an int32 map key", s)
+		}
+		return protoreflect.ValueOfInt32(int32(n)).MapKey(), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return protoreflect.MapKey{}, fmt.Errorf("%q is not an int64 map key", s)
+		}
+		return protoreflect.ValueOfInt64(n).MapKey(), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return protoreflect.MapKey{}, fmt.Errorf("%q is not a uint32 map key", s)
+		}
+		return protoreflect.ValueOfUint32(uint32(n)).MapKey(), nil
+	default:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return protoreflect.MapKey{}, fmt.Errorf("%q is not a uint64 map key", s)
+		}
+		return protoreflect.ValueOfUint64(n).MapKey(), nil
+	}
+}
+
+// protoScalarString returns the text of value, the value of a scalar field fd.
+func protoScalarString(value protoreflect.Value, fd protoreflect.FieldDescriptor) (string, error) {
+	if fd == nil || isCollection(value, fd) || fd.Message() != nil {
+		return "", fmt.Errorf("path does not end at a scalar field")
+	}
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(value.Enum()); ev != nil {
+			return string(ev.Name()), nil
+		}
+		return strconv.Itoa(int(value.Enum())), nil
+	case protoreflect.BytesKind:
+		return string(value.Bytes()), nil
+	default:
+		return value.String(), nil
+	}
+}
@@ -0,0 +1,184 @@
+package goldentest
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestCaptureProtoField(t *testing.T) {
+	value, err := structpb.NewStruct(map[string]any{
+		"name":  "users/42",
+		"count": 3,
+		"tags":  []any{"a", "b"},
+	})
+	if err != nil {
+		t.Fatalf("NewStruct: %v", err)
+	}
+	capture := CaptureProtoField[*structpb.Struct]()
+
+	for _, tt := range []struct {
+		path    string
+		want    string
+		wantErr string
+	}{
+		{path: "fields.name.string_value", want: "users/42"},
+		{path: "fields.count.number_value", want: "3"},
+		{path: "fields.tags.list_value.values.1.string_value", want: "b"},
+		{path: "fields.name.number_value", want: "0"},
+		{path: "fields.missing.string_value", wantErr: `fields: map has no key "missing"`},
+		{path: "fields.tags.list_value.values.2", wantErr: `"2" is not an index of a list of 2 elements`},
+		{path: "fields.name.nope", wantErr: `google.protobuf.Value has no field "nope"`},
+		{path: "fields.name", wantErr: "path does not end at a scalar field"},
+		{path: "fields.name.string_value.more", wantErr: `cannot select "more" from a string`},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := capture(value, tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("capture(%q) error = %v, want %q", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("capture(%q): %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Errorf("capture(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCaptures(t *testing.T) {
+	config := &TestConfig[string, struct{}]{
+		Capture: func(result, path string) (string, error) { return result, nil },
+	}
+	for _, tt := range []struct {
+		name    string
+		steps   []string
+		wantErr string
+	}{
+		{
+			name:  "valid",
+			steps: []string{"# capture id = id\ncreate", "get ${capture.id}"},
+		},
+		{
+			name:    "undeclared",
+			steps:   []string{"create", "get ${capture.id}"},
+			wantErr: "step 2 references ${capture.id}, which no earlier step captures",
+		},
+		{
+			name:    "same step",
+			steps:   []string{"# capture id = id\nget ${capture.id}"},
+			wantErr: "step 1 references ${capture.id}, which no earlier step captures",
+		},
+		{
+			name:    "duplicate",
+			steps:   []string{"# capture id = id", "# capture id = id"},
+			wantErr: "step 2 captures id, which step 1 already captures",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stepFiles []StepFile
+			for i, data := range tt.steps {
+				stepFiles = append(stepFiles, StepFile{Step: i + 1, Data: []byte(data)})
+			}
+			err := config.validateCaptures(stepFiles)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateCaptures: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("validateCaptures error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunStepTestsCaptures(t *testing.T) {
+	fsys := contents(map[string]string{
+		"create_then_get/1.in.hcl":   "# capture user_id = id\ncreate",
+		"create_then_get/1.out.json": "users/1",
+		"create_then_get/2.in.hcl":   "get ${capture.user_id}",
+		"create_then_get/2.out.json": "get users/1",
+	})
+
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		Capture: func(result, path string) (string, error) {
+			return result, nil
+		},
+		StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+			if stepFile.Step == 1 {
+				return "users/1", nil
+			}
+			return string(stepFile.Data), nil
+		},
+	}
+
+	config.RunTestsFS(t, fsys, ".")
+}
+
+func TestExpandCapturesEscapes(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "plain", value: "users/1", want: `get { name: "users/1" }`},
+		{name: "quote", value: `say "hi"`, want: `get { name: "say \"hi\"" }`},
+		{name: "backslash and newline", value: "a\\b\nc", want: `get { name: "a\\b\nc" }`},
+		{name: "control character", value: "a\x00b", wantErr: `captured value of id: "a\x00b" contains a control character that cannot be escaped in a step input`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stepFile := StepFile{Step: 2, Data: []byte(`get { name: "${capture.id}" }`)}
+			got, err := expandCaptures(stepFile, map[string]string{"id": tt.value})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expandCaptures error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandCaptures: %v", err)
+			}
+			if string(got.Data) != tt.want {
+				t.Errorf("expandCaptures = %s, want %s", got.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunStepTestsCapturesQuote(t *testing.T) {
+	// The captured value is only intact in step 2 if its quotes are escaped
+	fsys := contents(map[string]string{
+		"quoted/1.in.hcl":   "# capture title = title\ncreate",
+		"quoted/1.out.json": `the "best" title`,
+		"quoted/2.in.hcl":   `get "${capture.title}"`,
+		"quoted/2.out.json": `the "best" title`,
+	})
+
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		Capture: func(result, path string) (string, error) {
+			return result, nil
+		},
+		StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+			if stepFile.Step == 1 {
+				return `the "best" title`, nil
+			}
+			return strconv.Unquote(strings.TrimPrefix(string(stepFile.Data), "get "))
+		},
+	}
+
+	config.RunTestsFS(t, fsys, ".")
+}
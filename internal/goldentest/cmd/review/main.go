@@ -0,0 +1,118 @@
+// Command review walks the pending golden files written by
+// go test -update=pending, shows the diff of each against its golden file,
+// and asks whether to accept it in place of the golden file or reject it.
+//
+// Usage:
+//
+//	go run ./internal/goldentest/cmd/review [-accept | -reject] [dir ...]
+//
+// The directories default to the current directory. Like go test ./..., the
+// search skips directories whose names start with "." or "_", such as .git.
+// -accept and -reject accept or reject every pending file without asking.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/achew22/toy-project/internal/cli"
+	"github.com/achew22/toy-project/internal/goldentest"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		cli.Exit(err)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("review", flag.ContinueOnError)
+	acceptAll := flags.Bool("accept", false, "accept every pending golden file without asking")
+	rejectAll := flags.Bool("reject", false, "reject every pending golden file without asking")
+	if err := flags.Parse(args); err != nil {
+		return &cli.ExitError{Code: 2, Err: err}
+	}
+	if *acceptAll && *rejectAll {
+		return &cli.ExitError{Code: 2, Err: fmt.Errorf("-accept and -reject are mutually exclusive")}
+	}
+	dirs := flags.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	var pending []*goldentest.Pending
+	for _, dir := range dirs {
+		found, err := goldentest.FindPending(dir)
+		if err != nil {
+			return fmt.Errorf("failed to find pending golden files in %s: %w", dir, err)
+		}
+		pending = append(pending, found...)
+	}
+	if len(pending) == 0 {
+		fmt.Fprintln(stdout, "No pending golden files.")
+		return nil
+	}
+
+	color := goldentest.ColorDiffs()
+	answers := bufio.NewScanner(stdin)
+	var accepted, rejected, skipped int
+review:
+	for i, p := range pending {
+		action := "a"
+		switch {
+		case *acceptAll:
+		case *rejectAll:
+			action = "r"
+		default:
+			header := p.Golden
+			if p.Missing {
+				header += " (new golden file)"
+			}
+			fmt.Fprintf(stdout, "[%d/%d] %s\n%s", i+1, len(pending), header, p.Diff(color))
+			action = ask(answers, stdout)
+		}
+
+		switch action {
+		case "a", "A":
+			if err := p.Accept(); err != nil {
+				return fmt.Errorf("failed to accept %s: %w", p.Golden, err)
+			}
+			accepted++
+			if action == "A" {
+				*acceptAll = true
+			}
+		case "r":
+			if err := p.Reject(); err != nil {
+				return fmt.Errorf("failed to reject %s: %w", p.Golden, err)
+			}
+			rejected++
+		case "s":
+			skipped++
+		case "q":
+			skipped += len(pending) - i
+			break review
+		}
+	}
+	fmt.Fprintf(stdout, "accepted %d, rejected %d, skipped %d\n", accepted, rejected, skipped)
+	return nil
+}
+
+// ask prompts for what to do with a pending golden file until it gets a valid
+// answer. It returns "q" once there is no more input.
+func ask(answers *bufio.Scanner, stdout io.Writer) string {
+	for {
+		fmt.Fprint(stdout, "[a]ccept, [r]eject, [s]kip, [A]ccept all, [q]uit? ")
+		if !answers.Scan() {
+			fmt.Fprintln(stdout)
+			return "q"
+		}
+		switch answer := strings.TrimSpace(answers.Text()); answer {
+		case "a", "r", "s", "A", "q":
+			return answer
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/achew22/toy-project/internal/cli"
+)
+
+// writeFiles writes files, keyed by their path relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestRunReview(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	dir := t.TempDir()
+	// Pending files are reviewed in the order of their paths
+	writeFiles(t, dir, map[string]string{
+		"a.out.txt":     "old a\n",
+		"a.out.txt.new": "new a\n",
+		"b.out.txt":     "old b\n",
+		"b.out.txt.new": "new b\n",
+		"c.out.txt.new": "new c\n",
+		"d.out.txt":     "old d\n",
+		"d.out.txt.new": "new d\n",
+		"e.out.txt.new": "new e\n",
+	})
+
+	// An unknown answer is asked again
+	stdin := strings.NewReader("x\na\nr\ns\nq\n")
+	var stdout strings.Builder
+	if err := run([]string{dir}, stdin, &stdout); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	out := stdout.String()
+	for _, want := range []string{
+		"[1/5] " + filepath.Join(dir, "a.out.txt"),
+		"+new a",
+		"[3/5] " + filepath.Join(dir, "c.out.txt") + " (new golden file)",
+		"[4/5] ",
+		"accepted 1, rejected 1, skipped 3\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "[5/5]") {
+		t.Errorf("output shows the file after quitting:\n%s", out)
+	}
+	if got := strings.Count(out, "[a]ccept"); got != 5 {
+		t.Errorf("got %d prompts, want 5 for 4 files and an unknown answer:\n%s", got, out)
+	}
+
+	// Accepted files replace their golden file, rejected ones are deleted,
+	// and skipped ones are left for a later review
+	for name, want := range map[string]string{
+		"a.out.txt":     "new a\n",
+		"b.out.txt":     "old b\n",
+		"c.out.txt.new": "new c\n",
+		"d.out.txt":     "old d\n",
+		"d.out.txt.new": "new d\n",
+		"e.out.txt.new": "new e\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("failed to read %s: %v", name, err)
+			continue
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+	for _, name := range []string{"a.out.txt.new", "b.out.txt.new", "c.out.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s exists, want it removed or not created", name)
+		}
+	}
+}
+
+func TestRunReviewNothingPending(t *testing.T) {
+	var stdout strings.Builder
+	if err := run([]string{t.TempDir()}, strings.NewReader(""), &stdout); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got, want := stdout.String(), "No pending golden files.\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestRunReviewConflictingFlags(t *testing.T) {
+	err := run([]string{"-accept", "-reject", t.TempDir()}, strings.NewReader(""), &strings.Builder{})
+	var exitErr *cli.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 2 {
+		t.Errorf("run with -accept and -reject = %v, want an ExitError with code 2", err)
+	}
+}
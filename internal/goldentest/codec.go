@@ -0,0 +1,334 @@
+package goldentest
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Codec converts results to and from the content of golden files in one
+// format. Unless Formatter and Loader are set, RunTests uses the codec
+// registered for SuccessOutputExt.
+//
+// Codecs that serialize structured values store strings and byte slices
+// verbatim, since they are usually already formatted.
+type Codec interface {
+	// Format returns the golden file content for value.
+	Format(value any) ([]byte, error)
+	// Load decodes data into the value target points to, like json.Unmarshal.
+	Load(data []byte, target any) error
+}
+
+// Built-in codecs.
+var (
+	// TextCodec stores strings, byte slices and values implementing
+	// encoding.TextMarshaler as raw text. It is registered for ".txt".
+	TextCodec Codec = textCodec{}
+	// JSONCodec stores values as indented JSON, using protojson for proto
+	// messages. It is registered for ".json".
+	JSONCodec Codec = jsonCodec{}
+	// YAMLCodec stores values as YAML, converted from the JSON of JSONCodec so
+	// that json struct tags and protojson field names apply. It is registered
+	// for ".yaml" and ".yml".
+	YAMLCodec Codec = yamlCodec{}
+	// ProtoTextCodec stores proto messages as prototext. It is registered for
+	// ".textpb", ".txtpb" and ".pbtxt".
+	ProtoTextCodec Codec = protoTextCodec{}
+	// ProtoBinaryCodec stores the deterministic binary encoding of proto
+	// messages, or byte slices, as a hex dump in the format of hex.Dump, so that
+	// golden files remain diffable text. It is registered for ".binpb".
+	ProtoBinaryCodec Codec = protoBinaryCodec{}
+)
+
+// codecs holds the registered codecs by extension.
+var codecs = struct {
+	sync.RWMutex
+	byExt map[string]Codec
+}{byExt: map[string]Codec{
+	".txt":    TextCodec,
+	".json":   JSONCodec,
+	".yaml":   YAMLCodec,
+	".yml":    YAMLCodec,
+	".textpb": ProtoTextCodec,
+	".txtpb":  ProtoTextCodec,
+	".pbtxt":  ProtoTextCodec,
+	".binpb":  ProtoBinaryCodec,
+}}
+
+// RegisterCodec makes codec the codec for golden files with the extension
+// ext, such as ".csv", replacing any codec registered for it. It is meant to
+// be called from init functions or TestMain.
+func RegisterCodec(ext string, codec Codec) {
+	if !strings.HasPrefix(ext, ".") || codec == nil {
+		panic(fmt.Sprintf("goldentest: invalid codec registration for extension %q", ext))
+	}
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.byExt[ext] = codec
+}
+
+// LookupCodec returns the codec registered for the extension ext.
+func LookupCodec(ext string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	codec, ok := codecs.byExt[ext]
+	return codec, ok
+}
+
+// CodecFormatter returns a Formatter that formats results with codec.
+func CodecFormatter[T any](codec Codec) Formatter[T] {
+	return func(value T) ([]byte, error) {
+		return codec.Format(value)
+	}
+}
+
+// CodecLoader returns a Loader that loads results with codec.
+func CodecLoader[T any](codec Codec) Loader[T] {
+	return func(data []byte) (T, error) {
+		var result T
+		err := codec.Load(data, &result)
+		return result, err
+	}
+}
+
+// formatVerbatim returns value as is if it is a string or a byte slice.
+func formatVerbatim(value any) ([]byte, bool) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), true
+	case []byte:
+		return v, true
+	}
+	return nil, false
+}
+
+// loadVerbatim stores data in target if it points to a string or a byte
+// slice.
+func loadVerbatim(data []byte, target any) bool {
+	switch t := target.(type) {
+	case *string:
+		*t = string(data)
+	case *[]byte:
+		*t = data
+	default:
+		return false
+	}
+	return true
+}
+
+// newProtoTarget stores a new, empty message in target if it points to a
+// proto.Message variable, and returns the message.
+func newProtoTarget(target any) (proto.Message, bool) {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return nil, false
+	}
+	m, ok := v.Elem().Interface().(proto.Message)
+	if !ok {
+		return nil, false
+	}
+	msg := m.ProtoReflect().New().Interface()
+	v.Elem().Set(reflect.ValueOf(msg))
+	return msg, true
+}
+
+type textCodec struct{}
+
+func (textCodec) Format(value any) ([]byte, error) {
+	if data, ok := formatVerbatim(value); ok {
+		return data, nil
+	}
+	if m, ok := value.(encoding.TextMarshaler); ok {
+		return m.MarshalText()
+	}
+	return nil, fmt.Errorf("cannot format %T as text: it is not a string, []byte or encoding.TextMarshaler", value)
+}
+
+func (textCodec) Load(data []byte, target any) error {
+	if loadVerbatim(data, target) {
+		return nil
+	}
+	if u, ok := target.(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText(data)
+	}
+	return fmt.Errorf("cannot load text into %T: it is not a string, []byte or encoding.TextUnmarshaler", target)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Format(value any) ([]byte, error) {
+	if data, ok := formatVerbatim(value); ok {
+		return data, nil
+	}
+	if m, ok := value.(proto.Message); ok {
+		data, err := protojson.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		// protojson randomly varies its whitespace so that its output is not
+		// relied on to be stable; reindenting it makes it stable.
+		var indented bytes.Buffer
+		err = json.Indent(&indented, data, "", "  ")
+		return indented.Bytes(), err
+	}
+	return json.MarshalIndent(value, "", "  ")
+}
+
+func (jsonCodec) Load(data []byte, target any) error {
+	if loadVerbatim(data, target) {
+		return nil
+	}
+	if msg, ok := newProtoTarget(target); ok {
+		return protojson.Unmarshal(data, msg)
+	}
+	return json.Unmarshal(data, target)
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Format(value any) ([]byte, error) {
+	if data, ok := formatVerbatim(value); ok {
+		return data, nil
+	}
+	data, err := JSONCodec.Format(value)
+	if err != nil {
+		return nil, err
+	}
+	// Decoding JSON into a node keeps the order of its fields.
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	resetYAMLStyle(&node)
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	err = enc.Close()
+	return b.Bytes(), err
+}
+
+func (yamlCodec) Load(data []byte, target any) error {
+	if loadVerbatim(data, target) {
+		return nil
+	}
+	var value any
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("YAML value cannot be converted to JSON: %w", err)
+	}
+	return JSONCodec.Load(data, target)
+}
+
+// resetYAMLStyle clears the JSON flow and quoting styles of node and its
+// descendants, so that they are encoded as block style YAML.
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+type protoTextCodec struct{}
+
+func (protoTextCodec) Format(value any) ([]byte, error) {
+	if data, ok := formatVerbatim(value); ok {
+		return data, nil
+	}
+	m, ok := value.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("cannot format %T as prototext: it is not a proto.Message", value)
+	}
+	data, err := prototext.MarshalOptions{
+		Multiline: true,
+		Indent:    "  ",
+	}.Marshal(m)
+	return stableProtoText(data), err
+}
+
+func (protoTextCodec) Load(data []byte, target any) error {
+	if loadVerbatim(data, target) {
+		return nil
+	}
+	msg, ok := newProtoTarget(target)
+	if !ok {
+		return fmt.Errorf("cannot load prototext into %T: it does not point to a proto.Message", target)
+	}
+	return prototext.Unmarshal(data, msg)
+}
+
+type protoBinaryCodec struct{}
+
+func (protoBinaryCodec) Format(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return []byte(hex.Dump(v)), nil
+	case proto.Message:
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(hex.Dump(data)), nil
+	}
+	return nil, fmt.Errorf("cannot format %T as binary proto: it is not a proto.Message or []byte", value)
+}
+
+func (protoBinaryCodec) Load(data []byte, target any) error {
+	if t, ok := target.(*[]byte); ok {
+		var err error
+		*t, err = parseHexDump(data)
+		return err
+	}
+	msg, ok := newProtoTarget(target)
+	if !ok {
+		return fmt.Errorf("cannot load binary proto into %T: it does not point to a proto.Message or []byte", target)
+	}
+	wire, err := parseHexDump(data)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(wire, msg)
+}
+
+// parseHexDump returns the bytes of a dump in the format of hex.Dump: lines of
+// an offset, up to 16 hex bytes and the bytes as text between bars.
+func parseHexDump(data []byte) ([]byte, error) {
+	var out []byte
+	for i, line := range splitLines(string(data)) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		offset, err := strconv.ParseUint(fields[0], 16, 64)
+		if err != nil || offset != uint64(len(out)) {
+			return nil, fmt.Errorf("line %d of hex dump: expected offset %08x, got %q", i+1, len(out), fields[0])
+		}
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "|") {
+				break
+			}
+			b, err := hex.DecodeString(field)
+			if err != nil || len(b) != 1 {
+				return nil, fmt.Errorf("line %d of hex dump: invalid byte %q", i+1, field)
+			}
+			out = append(out, b[0])
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,128 @@
+package goldentest
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// testCodec checks that codec formats value as want and loads it back.
+func testCodec[T any](t *testing.T, codec Codec, value T, want string) {
+	t.Helper()
+	got, err := CodecFormatter[T](codec)(value)
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("formatted %T mismatch (-want +got):\n%s", value, diff)
+	}
+	loaded, err := CodecLoader[T](codec)([]byte(want))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if diff := cmp.Diff(value, loaded, protocmp.Transform()); diff != "" {
+		t.Errorf("loaded %T mismatch (-want +got):\n%s", value, diff)
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	type record struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	msg := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String("id"),
+		Number: proto.Int32(1),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+
+	t.Run("text", func(t *testing.T) {
+		testCodec(t, TextCodec, "hello\n", "hello\n")
+	})
+	t.Run("json", func(t *testing.T) {
+		testCodec(t, JSONCodec, record{Name: "a", Count: 2}, "{\n  \"name\": \"a\",\n  \"count\": 2\n}")
+		testCodec(t, JSONCodec, msg, "{\n  \"name\": \"id\",\n  \"number\": 1,\n  \"label\": \"LABEL_OPTIONAL\"\n}")
+		testCodec(t, JSONCodec, "verbatim", "verbatim")
+	})
+	t.Run("yaml", func(t *testing.T) {
+		testCodec(t, YAMLCodec, record{Name: "a", Count: 2}, "name: a\ncount: 2\n")
+		testCodec(t, YAMLCodec, msg, "name: id\nnumber: 1\nlabel: LABEL_OPTIONAL\n")
+		testCodec(t, YAMLCodec, map[string]string{"version": "1.0"}, "version: \"1.0\"\n")
+	})
+	t.Run("prototext", func(t *testing.T) {
+		testCodec(t, ProtoTextCodec, msg, "name: \"id\"\nnumber: 1\nlabel: LABEL_OPTIONAL\n")
+	})
+	t.Run("binary proto", func(t *testing.T) {
+		testCodec(t, ProtoBinaryCodec, msg, "00000000  0a 02 69 64 18 01 20 01                           |..id.. .|\n")
+		testCodec(t, ProtoBinaryCodec, bytes.Repeat([]byte("ab"), 9), ""+
+			"00000000  61 62 61 62 61 62 61 62  61 62 61 62 61 62 61 62  |abababababababab|\n"+
+			"00000010  61 62                                             |ab|\n")
+	})
+}
+
+func TestCodecUnsupportedType(t *testing.T) {
+	if _, err := ProtoTextCodec.Format(struct{}{}); err == nil || !strings.Contains(err.Error(), "not a proto.Message") {
+		t.Errorf("Format(struct{}{}) = %v, want an error about proto.Message", err)
+	}
+	var n int
+	if err := TextCodec.Load([]byte("1"), &n); err == nil {
+		t.Error("Load into *int succeeded, want an error")
+	}
+}
+
+func TestParseHexDump(t *testing.T) {
+	for _, tc := range []struct {
+		name, dump, want string
+	}{
+		{"wrong offset", "00000010  61 62  |ab|\n", "expected offset 00000000"},
+		{"invalid byte", "00000000  61 zz  |a.|\n", `invalid byte "zz"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseHexDump([]byte(tc.dump))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("parseHexDump(%q) = %v, want an error containing %q", tc.dump, err, tc.want)
+			}
+		})
+	}
+}
+
+// reverseCodec stores strings reversed.
+type reverseCodec struct{}
+
+func reverse(s string) string {
+	r := []rune(s)
+	slices.Reverse(r)
+	return string(r)
+}
+
+func (reverseCodec) Format(value any) ([]byte, error) {
+	return []byte(reverse(value.(string))), nil
+}
+
+func (reverseCodec) Load(data []byte, target any) error {
+	*target.(*string) = reverse(string(data))
+	return nil
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec(".rev", reverseCodec{})
+	defer func() {
+		codecs.Lock()
+		delete(codecs.byExt, ".rev")
+		codecs.Unlock()
+	}()
+
+	fsys := contents(map[string]string{
+		"greeting.in":      "hello",
+		"greeting.out.rev": "olleh",
+	})
+	NewOneShotConfig(func(_ struct{}, _ string, data []byte) (string, error) {
+		return string(data), nil
+	}).WithInputExt(".in").WithSuccessExt(".rev").RunTestsFS(t, fsys, ".")
+}
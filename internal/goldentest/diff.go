@@ -0,0 +1,305 @@
+package goldentest
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// ANSI escape sequences used to color diffs.
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorCyan   = "\x1b[36m"
+	noColorName = "NO_COLOR"
+)
+
+// colorDiffs is whether diffs are colored, as reported by ColorDiffs when the
+// test binary starts.
+var colorDiffs = ColorDiffs()
+
+// ColorDiffs reports whether diffs should be colored: only when stdout is a
+// terminal and NO_COLOR (https://no-color.org) is not set.
+func ColorDiffs() bool {
+	if _, ok := os.LookupEnv(noColorName); ok {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// diffOp is the kind of a line in a diff.
+type diffOp byte
+
+const (
+	diffEqual  diffOp = ' '
+	diffDelete diffOp = '-'
+	diffInsert diffOp = '+'
+)
+
+// diffLine is a line of a diff. oldLine and newLine are the 1-based line
+// numbers of the line in each side, or 0 if it is not in that side.
+type diffLine struct {
+	op               diffOp
+	text             string
+	oldLine, newLine int
+}
+
+// unifiedDiff returns a unified diff from the golden file at path, whose
+// content is want, to got. It returns "" if they are equal.
+func unifiedDiff(path string, want, got []byte, color bool) string {
+	lines := diffLines(splitLines(string(want)), splitLines(string(got)))
+
+	var hunks [][]diffLine
+	for i := 0; i < len(lines); {
+		if lines[i].op == diffEqual {
+			i++
+			continue
+		}
+		// Grow the hunk until there are more than two contexts' worth of
+		// unchanged lines before the next change.
+		start := max(i-diffContext, 0)
+		end := i
+		for end < len(lines) {
+			if lines[end].op != diffEqual {
+				end++
+				continue
+			}
+			next := end
+			for next < len(lines) && lines[next].op == diffEqual {
+				next++
+			}
+			if next == len(lines) || next-end > 2*diffContext {
+				end = min(end+diffContext, len(lines))
+				break
+			}
+			end = next
+		}
+		hunks = append(hunks, lines[start:end])
+		i = end
+	}
+	if len(hunks) == 0 && string(want) == string(got) {
+		return ""
+	}
+
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+
+	var b strings.Builder
+	b.WriteString(paint(colorBold, "--- "+path+" (golden)") + "\n")
+	b.WriteString(paint(colorBold, "+++ "+path+" (got)") + "\n")
+	if len(hunks) == 0 {
+		b.WriteString("(only trailing newlines differ)\n")
+	}
+	for _, hunk := range hunks {
+		oldStart, oldCount, newStart, newCount := hunkRange(hunk)
+		b.WriteString(paint(colorCyan, fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldStart, oldCount, newStart, newCount)) + "\n")
+		for _, line := range hunk {
+			text := string(line.op) + line.text
+			switch line.op {
+			case diffDelete:
+				text = paint(colorRed, text)
+			case diffInsert:
+				text = paint(colorGreen, text)
+			}
+			b.WriteString(text + "\n")
+		}
+	}
+	return b.String()
+}
+
+// hunkRange returns the start line and line count of a hunk in each side, as
+// shown in its @@ header.
+func hunkRange(hunk []diffLine) (oldStart, oldCount, newStart, newCount int) {
+	for _, line := range hunk {
+		if line.oldLine != 0 {
+			if oldStart == 0 {
+				oldStart = line.oldLine
+			}
+			oldCount++
+		}
+		if line.newLine != 0 {
+			if newStart == 0 {
+				newStart = line.newLine
+			}
+			newCount++
+		}
+	}
+	// An empty side is shown as starting at the line before the hunk.
+	if oldStart == 0 {
+		oldStart = precedingLine(hunk, func(l diffLine) int { return l.oldLine })
+	}
+	if newStart == 0 {
+		newStart = precedingLine(hunk, func(l diffLine) int { return l.newLine })
+	}
+	return oldStart, oldCount, newStart, newCount
+}
+
+// precedingLine returns the line number, in the side selected by lineOf, of
+// the line before a hunk that has no lines in that side.
+func precedingLine(hunk []diffLine, lineOf func(diffLine) int) int {
+	for _, line := range hunk {
+		if line.op == diffEqual {
+			return lineOf(line) - 1
+		}
+	}
+	return 0
+}
+
+// splitLines splits s into lines without their terminating newlines.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// maxDiffEdits bounds the number of deleted and inserted lines diffLines
+// searches for. Finding a minimal diff costs time and memory that grow with
+// its square, so past this the differing lines are shown as all deleted and
+// then all inserted instead.
+const maxDiffEdits = 1000
+
+// diffLines returns a line diff from a to b, minimal unless it needs more than
+// maxDiffEdits deletions and insertions.
+func diffLines(a, b []string) []diffLine {
+	// Common prefixes and suffixes are by far the most common case for golden
+	// files, so strip them before searching for the differences.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	var lines []diffLine
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, diffLine{op: diffEqual, text: a[i], oldLine: i + 1, newLine: i + 1})
+	}
+	i, j := 0, 0
+	for _, op := range diffOps(ma, mb) {
+		switch op {
+		case diffEqual:
+			lines = append(lines, diffLine{op: diffEqual, text: ma[i], oldLine: prefix + i + 1, newLine: prefix + j + 1})
+			i++
+			j++
+		case diffDelete:
+			lines = append(lines, diffLine{op: diffDelete, text: ma[i], oldLine: prefix + i + 1})
+			i++
+		case diffInsert:
+			lines = append(lines, diffLine{op: diffInsert, text: mb[j], newLine: prefix + j + 1})
+			j++
+		}
+	}
+	for k := 0; k < suffix; k++ {
+		oldLine, newLine := len(a)-suffix+k, len(b)-suffix+k
+		lines = append(lines, diffLine{op: diffEqual, text: a[oldLine], oldLine: oldLine + 1, newLine: newLine + 1})
+	}
+	return lines
+}
+
+// diffOps returns the edit script from a to b found by Myers' O(ND) diff
+// algorithm. If it needs more than maxDiffEdits edits, every line of a is
+// deleted and every line of b inserted.
+func diffOps(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxDiffEdits)
+	// v[k+limit+1] is the furthest x reached on diagonal k = x-y, and
+	// trace[d] is v before searching d edits, restricted to diagonals -d..d.
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[limit+1-d:limit+2+d]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[limit+k] < v[limit+k+2]) {
+				x = v[limit+k+2] // insertion: down from diagonal k+1
+			} else {
+				x = v[limit+k] + 1 // deletion: right from diagonal k-1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[limit+k+1] = x
+			if x >= n && y >= m {
+				return backtrackOps(trace, n, m)
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	for range a {
+		ops = append(ops, diffDelete)
+	}
+	for range b {
+		ops = append(ops, diffInsert)
+	}
+	return ops
+}
+
+// backtrackOps follows the furthest reaching paths recorded by diffOps back
+// from (n, m) to the start, and returns the edits along the way in order.
+func backtrackOps(trace [][]int, n, m int) []diffOp {
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		// at returns the furthest x on diagonal k after d-1 edits.
+		at := func(k int) int { return trace[d][k+d] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, diffEqual)
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			ops = append(ops, diffInsert)
+		} else {
+			ops = append(ops, diffDelete)
+		}
+		x, y = prevX, prevY
+	}
+	slices.Reverse(ops)
+	return ops
+}
+
+// mismatchReport describes why the golden file at path, whose content is
+// golden, does not match. The actual value is formatted with the config's
+// Formatter and shown as a unified diff against the golden file as it is on
+// disk, so that its line numbers point into the file; semanticDiff, the
+// cmp.Diff of the values, is shown instead if formatting fails or hides the
+// difference.
+func (config *TestConfig[T, F]) mismatchReport(path string, golden []byte, actual T, semanticDiff string) string {
+	if got, err := config.Formatter(actual); err == nil {
+		if diff := unifiedDiff(path, golden, got, colorDiffs); diff != "" {
+			return diff
+		}
+	}
+	return fmt.Sprintf("%s (-golden +got):\n%s", path, semanticDiff)
+}
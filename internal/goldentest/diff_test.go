@@ -0,0 +1,152 @@
+package goldentest
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name      string
+		want, got string
+		diff      string
+	}{
+		{
+			name: "equal",
+			want: "a\nb\n",
+			got:  "a\nb\n",
+			diff: "",
+		},
+		{
+			name: "changed line",
+			want: "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			got:  "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			diff: "--- golden.txt (golden)\n+++ golden.txt (got)\n" +
+				"@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			want: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			got:  "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			diff: "--- golden.txt (golden)\n+++ golden.txt (got)\n" +
+				"@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -9,4 +9,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+		{
+			name: "insertion into empty",
+			want: "",
+			got:  "a\n",
+			diff: "--- golden.txt (golden)\n+++ golden.txt (got)\n@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			name: "trailing newline",
+			want: "a\n",
+			got:  "a",
+			diff: "--- golden.txt (golden)\n+++ golden.txt (got)\n(only trailing newlines differ)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff("golden.txt", []byte(tt.want), []byte(tt.got), false)
+			if diff := cmp.Diff(tt.diff, got); diff != "" {
+				t.Errorf("unifiedDiff mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiffColor(t *testing.T) {
+	got := unifiedDiff("golden.txt", []byte("a\n"), []byte("b\n"), true)
+	want := colorBold + "--- golden.txt (golden)" + colorReset + "\n" +
+		colorBold + "+++ golden.txt (got)" + colorReset + "\n" +
+		colorCyan + "@@ -1,1 +1,1 @@" + colorReset + "\n" +
+		colorRed + "-a" + colorReset + "\n" +
+		colorGreen + "+b" + colorReset + "\n"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unifiedDiff mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiffOpsMinimal(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	randomLines := func() []string {
+		lines := make([]string, rng.IntN(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.IntN(3)))
+		}
+		return lines
+	}
+	// lcsLen is the length of the longest common subsequence of a and b.
+	lcsLen := func(a, b []string) int {
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		return lcs[0][0]
+	}
+
+	for range 500 {
+		a, b := randomLines(), randomLines()
+		var gotA, gotB []string
+		edits := 0
+		for _, line := range diffLines(a, b) {
+			if line.op != diffInsert {
+				gotA = append(gotA, line.text)
+			}
+			if line.op != diffDelete {
+				gotB = append(gotB, line.text)
+			}
+			if line.op != diffEqual {
+				edits++
+			}
+		}
+		if !slices.Equal(gotA, a) || !slices.Equal(gotB, b) {
+			t.Fatalf("diff of %q and %q does not rebuild them: got %q and %q", a, b, gotA, gotB)
+		}
+		if want := len(a) + len(b) - 2*lcsLen(a, b); edits != want {
+			t.Fatalf("diff of %q and %q has %d edits, want %d", a, b, edits, want)
+		}
+	}
+}
+
+func TestDiffOpsTooManyEdits(t *testing.T) {
+	var a, b []string
+	for i := 0; i < maxDiffEdits; i++ {
+		a = append(a, fmt.Sprintf("old %d", i))
+		b = append(b, fmt.Sprintf("new %d", i), "same")
+	}
+	ops := diffOps(a, b)
+	want := slices.Repeat([]diffOp{diffDelete}, len(a))
+	want = append(want, slices.Repeat([]diffOp{diffInsert}, len(b))...)
+	if !slices.Equal(ops, want) {
+		t.Errorf("diffOps past maxDiffEdits did not delete every old line and insert every new one")
+	}
+}
+
+func TestMismatchReportDiffsGoldenFile(t *testing.T) {
+	config := &TestConfig[string, struct{}]{
+		Formatter: func(s string) ([]byte, error) { return []byte(s + "\n"), nil },
+	}
+	// The golden file has a comment that its loaded value, and so a
+	// re-formatted expected value, would not have
+	golden := []byte("# greeting\nhello\nbye\n")
+	got := config.mismatchReport("greet.out.txt", golden, "hello\nsee you", "unused")
+	want := "--- greet.out.txt (golden)\n+++ greet.out.txt (got)\n" +
+		"@@ -1,3 +1,2 @@\n-# greeting\n hello\n-bye\n+see you\n"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatchReport mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,210 @@
+package goldentest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	hcl "github.com/hashicorp/hcl/v2"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+)
+
+// ErrorGolden stores errors in error outputs as structured values, which are
+// compared like results instead of byte for byte. Set TestConfig.ErrorGolden
+// to a TypedErrors instead of ErrorFunc to use it.
+type ErrorGolden interface {
+	// formatError returns the content of the error output for err, whose
+	// extension is ext.
+	formatError(err error, ext string) ([]byte, error)
+	// diffErrors returns the differences between the values stored in golden
+	// and actual, the contents of error outputs, or "" if they are equal.
+	diffErrors(golden, actual []byte, ext string) (string, error)
+}
+
+// TypedErrors is an ErrorGolden that converts errors to values of type E, such
+// as a google.rpc.Status or a list of diagnostics. DiffOpts can then ignore
+// parts of errors that tests should not depend on, such as the wording of
+// messages, while still asserting codes and source ranges.
+//
+// Example:
+//
+//	config.ErrorGolden = &goldentest.TypedErrors[*spb.Status]{
+//		Convert: func(err error) (*spb.Status, error) {
+//			return status.Convert(err).Proto(), nil
+//		},
+//		DiffOpts: []cmp.Option{
+//			protocmp.Transform(),
+//			protocmp.IgnoreFields(&spb.Status{}, "message"),
+//		},
+//	}
+type TypedErrors[E any] struct {
+	// Convert returns the value stored for err. An error from Convert fails the
+	// test case.
+	Convert func(err error) (E, error)
+	// Formatter formats values for error outputs. If not set, the Codec
+	// registered for ErrorOutputExt is used, or DefaultFormatter[E]() if
+	// there is none.
+	Formatter Formatter[E]
+	// Loader loads values from error outputs. If not set, the Codec registered
+	// for ErrorOutputExt is used, or DefaultLoader[E]() if there is none.
+	Loader Loader[E]
+	// DiffOpts are passed to cmp.Diff, after cmpopts.EquateEmpty(), to compare
+	// values.
+	DiffOpts []cmp.Option
+}
+
+func (te *TypedErrors[E]) formatError(testErr error, ext string) ([]byte, error) {
+	value, err := te.Convert(testErr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert error: %w", err)
+	}
+	formatter, _ := te.codec(ext)
+	return formatter(value)
+}
+
+func (te *TypedErrors[E]) diffErrors(golden, actual []byte, ext string) (string, error) {
+	_, loader := te.codec(ext)
+	want, err := loader(golden)
+	if err != nil {
+		return "", fmt.Errorf("failed to load expected error: %w", err)
+	}
+	got, err := loader(actual)
+	if err != nil {
+		return "", fmt.Errorf("failed to load error: %w", err)
+	}
+	return cmp.Diff(want, got, append([]cmp.Option{cmpopts.EquateEmpty()}, te.DiffOpts...)...), nil
+}
+
+// codec returns the Formatter and Loader for error outputs with the
+// extension ext.
+func (te *TypedErrors[E]) codec(ext string) (Formatter[E], Loader[E]) {
+	codec, ok := LookupCodec(ext)
+	if !ok {
+		codec = defaultCodec[E]()
+	}
+	formatter, loader := te.Formatter, te.Loader
+	if formatter == nil {
+		formatter = CodecFormatter[E](codec)
+	}
+	if loader == nil {
+		loader = CodecLoader[E](codec)
+	}
+	return formatter, loader
+}
+
+// StatusErrors returns TypedErrors that store gRPC errors as google.rpc.Status
+// messages, including their typed details, compared with protocmp. Errors that
+// are not gRPC errors are stored with code Unknown. opts are added to the
+// DiffOpts, e.g. protocmp.IgnoreFields(&spb.Status{}, "message") to only
+// assert codes and details.
+func StatusErrors(opts ...cmp.Option) *TypedErrors[*spb.Status] {
+	return &TypedErrors[*spb.Status]{
+		Convert: func(err error) (*spb.Status, error) {
+			return status.Convert(err).Proto(), nil
+		},
+		DiffOpts: append([]cmp.Option{protocmp.Transform()}, opts...),
+	}
+}
+
+// Diagnostic is an HCL diagnostic as stored by DiagnosticErrors.
+type Diagnostic struct {
+	// Severity is "error" or "warning".
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail,omitempty"`
+	// Range is the source range of the diagnostic, such as
+	// "testdata/server.hcl:3,5-12", or empty if it has none.
+	Range string `json:"range,omitempty"`
+}
+
+// DiagnosticErrors returns TypedErrors that store hcl.Diagnostics as a list of
+// Diagnostic. Other errors are stored as a single diagnostic whose summary is
+// the error message. opts are added to the DiffOpts, e.g.
+// cmpopts.IgnoreFields(goldentest.Diagnostic{}, "Detail") to only assert
+// summaries and ranges.
+func DiagnosticErrors(opts ...cmp.Option) *TypedErrors[[]Diagnostic] {
+	return &TypedErrors[[]Diagnostic]{
+		Convert: func(err error) ([]Diagnostic, error) {
+			var diags hcl.Diagnostics
+			if !errors.As(err, &diags) {
+				return []Diagnostic{{Severity: "error", Summary: err.Error()}}, nil
+			}
+			result := make([]Diagnostic, 0, len(diags))
+			for _, diag := range diags {
+				d := Diagnostic{Severity: "error", Summary: diag.Summary, Detail: diag.Detail}
+				if diag.Severity == hcl.DiagWarning {
+					d.Severity = "warning"
+				}
+				if diag.Subject != nil {
+					d.Range = diag.Subject.String()
+				}
+				result = append(result, d)
+			}
+			return result, nil
+		},
+		DiffOpts: opts,
+	}
+}
+
+// errorHandling reports whether errors are compared against error outputs,
+// with either ErrorFunc or ErrorGolden.
+func (config *TestConfig[T, F]) errorHandling() bool {
+	return config.ErrorFunc != nil || config.ErrorGolden != nil
+}
+
+// errorOutput returns the content of the error output for testErr.
+func (config *TestConfig[T, F]) errorOutput(testErr error, placeholders *Placeholders) ([]byte, error) {
+	if config.ErrorGolden == nil {
+		return config.scrubText(config.ErrorFunc(testErr), placeholders), nil
+	}
+	data, err := config.ErrorGolden.formatError(testErr, config.ErrorOutputExt)
+	if err != nil {
+		return nil, err
+	}
+	return config.scrubText(data, placeholders), nil
+}
+
+// testErrorOutput compares testErr against the named error output. subject is
+// the file the error is reported for.
+func (config *TestConfig[T, F]) testErrorOutput(t *testing.T, files caseFiles, subject, name string, testErr error, placeholders *Placeholders) {
+	actualError, err := config.errorOutput(testErr, placeholders)
+	if err != nil {
+		t.Errorf("failed to format error output for file %s: %v\nerror: %v", subject, err, testErr)
+		return
+	}
+	expectedError, ok := readGolden(t, files, name, func() ([]byte, error) { return actualError, nil })
+	if !ok {
+		return
+	}
+
+	path := files.path(name)
+	diff := unifiedDiff(path, expectedError, actualError, colorDiffs)
+	if config.ErrorGolden != nil && diff != "" {
+		// Structured errors only mismatch if their values differ, so that
+		// DiffOpts can ignore some of their fields, and the difference of the
+		// values is what is reported.
+		semanticDiff, err := config.ErrorGolden.diffErrors(expectedError, actualError, config.ErrorOutputExt)
+		if err != nil {
+			t.Errorf("failed to compare error output %s: %v", path, err)
+			return
+		}
+		diff = ""
+		if semanticDiff != "" {
+			diff = fmt.Sprintf("%s (-golden +got):\n%s", path, semanticDiff)
+		}
+	}
+	if diff != "" {
+		reportDiff(t, diff)
+		if Update.rewrites(t.Name()) {
+			updateGolden(t, files, name, actualError)
+			return
+		}
+		writePending(t, files, name, func() ([]byte, error) { return actualError, nil })
+		t.Errorf("error output mismatch for file %s:\n%s", subject, diff)
+	}
+}
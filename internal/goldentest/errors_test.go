@@ -0,0 +1,146 @@
+package goldentest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	hcl "github.com/hashicorp/hcl/v2"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+)
+
+func TestStatusErrors(t *testing.T) {
+	fsys := contents(map[string]string{
+		// The golden message is worded differently, which the config ignores.
+		"get_missing/1.in.hcl": "get alice",
+		"get_missing/1.out.textpb": `code: 5
+message: "no user named alice"
+details: {
+  [type.googleapis.com/google.rpc.ResourceInfo]: {
+    resource_type: "user"
+    resource_name: "alice"
+  }
+}`,
+	})
+
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		ErrorOutputExt:   ".textpb",
+		ErrorGolden:      StatusErrors(protocmp.IgnoreFields(&spb.Status{}, "message")),
+		StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+			st, err := status.New(codes.NotFound, "user alice not found").WithDetails(&errdetails.ResourceInfo{
+				ResourceType: "user",
+				ResourceName: "alice",
+			})
+			if err != nil {
+				return "", err
+			}
+			return "", st.Err()
+		},
+	}
+
+	config.RunTestsFS(t, fsys, ".")
+}
+
+func TestDiagnosticErrors(t *testing.T) {
+	fsys := contents(map[string]string{
+		"error_unclosed.hcl": "listen {\n",
+		"error_unclosed.out.json": `[
+  {
+    "severity": "error",
+    "summary": "Unclosed configuration block",
+    "detail": "This block is not closed.",
+    "range": "error_unclosed.hcl:1,8-9"
+  }
+]`,
+	})
+
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".txt",
+		ErrorOutputExt:   ".json",
+		ErrorGolden:      DiagnosticErrors(cmpopts.IgnoreFields(Diagnostic{}, "Detail")),
+		TestOneShotFunc: func(_ struct{}, filePath string, data []byte) (string, error) {
+			if _, diags := hclsyntax.ParseConfig(data, filePath, hcl.InitialPos); diags.HasErrors() {
+				return "", diags
+			}
+			return "ok", nil
+		},
+	}
+
+	config.RunTestsFS(t, fsys, ".")
+}
+
+func TestTypedErrorsDiff(t *testing.T) {
+	errorGolden := StatusErrors(protocmp.IgnoreFields(&spb.Status{}, "message"))
+
+	golden, err := errorGolden.formatError(status.Error(codes.NotFound, "not found"), ".textpb")
+	if err != nil {
+		t.Fatalf("formatError: %v", err)
+	}
+	for _, tc := range []struct {
+		name     string
+		err      error
+		wantDiff bool
+	}{
+		{"same", status.Error(codes.NotFound, "not found"), false},
+		{"reworded", status.Error(codes.NotFound, "user is missing"), false},
+		{"other code", status.Error(codes.PermissionDenied, "not found"), true},
+		{"not a status", context.Canceled, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := errorGolden.formatError(tc.err, ".textpb")
+			if err != nil {
+				t.Fatalf("formatError: %v", err)
+			}
+			diff, err := errorGolden.diffErrors(golden, actual, ".textpb")
+			if err != nil {
+				t.Fatalf("diffErrors: %v", err)
+			}
+			if got := diff != ""; got != tc.wantDiff {
+				t.Errorf("diffErrors reported a difference: %v, want %v\n%s", got, tc.wantDiff, diff)
+			}
+		})
+	}
+}
+
+func TestStatusErrorsMismatchReportsValues(t *testing.T) {
+	withReport(t, "report.json")
+	defer func(update UpdateFlag) { *Update = update }(*Update)
+	*Update = UpdateFlag{Mode: UpdateAll}
+
+	fsys := contents(map[string]string{
+		"get_missing/1.in.hcl":     "get alice",
+		"get_missing/1.out.textpb": "code: 5\nmessage: \"no user named alice\"\n",
+	})
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		ErrorOutputExt:   ".textpb",
+		ErrorGolden:      StatusErrors(protocmp.IgnoreFields(&spb.Status{}, "message")),
+		StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+			return "", status.Error(codes.PermissionDenied, "user alice is hidden")
+		},
+	}
+	t.Run("suite", func(t *testing.T) {
+		config.RunTestsFS(t, fsys, ".")
+	})
+
+	entry := findReportEntry(reports.cases, "TestStatusErrorsMismatchReportsValues/suite/get_missing/step_1")
+	if entry == nil {
+		t.Fatal("no report entry for step_1")
+	}
+	// The mismatch is reported as the difference of the status values, which
+	// leaves out the ignored message, not as a diff of the file contents.
+	if !strings.HasPrefix(entry.Diff, "get_missing/1.out.textpb (-golden +got):\n") || strings.Contains(entry.Diff, "message") {
+		t.Errorf("reported diff is not the difference of the status values:\n%s", entry.Diff)
+	}
+}
@@ -0,0 +1,313 @@
+package goldentest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/txtar"
+)
+
+// ArchiveExt is the extension of step test cases stored as a single txtar
+// archive instead of a directory.
+const ArchiveExt = ".txtar"
+
+// WritableFS is an fs.FS whose files can be changed, which -update needs to
+// write golden files. Names are slash-separated paths, as in fs.FS.
+type WritableFS interface {
+	fs.FS
+	// WriteFile creates or replaces the named file with data.
+	WriteFile(name string, data []byte) error
+	// Remove deletes the named file.
+	Remove(name string) error
+}
+
+// DirFS returns a WritableFS for the tree of files rooted at dir. Files are
+// written atomically, and messages show their paths on disk.
+func DirFS(dir string) WritableFS {
+	return dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+// dirFS is the WritableFS returned by DirFS.
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+func (d dirFS) WriteFile(name string, data []byte) error {
+	return writeGolden(d.path(name), data)
+}
+
+func (d dirFS) Remove(name string) error {
+	return os.Remove(d.path(name))
+}
+
+// path returns the path on disk of the named file.
+func (d dirFS) path(name string) string {
+	return filepath.Join(d.dir, filepath.FromSlash(name))
+}
+
+// displayPath returns the path of the named file in fsys, for messages and
+// StepFile.FilePath: its path on disk if fsys is a DirFS.
+func displayPath(fsys fs.FS, name string) string {
+	if d, ok := fsys.(dirFS); ok {
+		return d.path(name)
+	}
+	return name
+}
+
+// writeFile writes the named file of fsys, which must be a WritableFS.
+func writeFile(fsys fs.FS, name string, data []byte) error {
+	w, ok := fsys.(WritableFS)
+	if !ok {
+		return fmt.Errorf("cannot write %s: golden files are read from a read-only %T; run the tests on a goldentest.DirFS or another WritableFS to update them", name, fsys)
+	}
+	return w.WriteFile(name, data)
+}
+
+// removeFile removes the named file of fsys, which must be a WritableFS.
+func removeFile(fsys fs.FS, name string) error {
+	w, ok := fsys.(WritableFS)
+	if !ok {
+		return fmt.Errorf("cannot remove %s: golden files are read from a read-only %T; run the tests on a goldentest.DirFS or another WritableFS to update them", name, fsys)
+	}
+	return w.Remove(name)
+}
+
+// caseFiles holds the input and output files of a test case: a directory, or
+// the sections of a txtar archive.
+type caseFiles interface {
+	// names returns the sorted names of the files.
+	names() ([]string, error)
+	// has reports whether the named file exists.
+	has(name string) bool
+	// read returns the content of the named file, or an error wrapping
+	// fs.ErrNotExist if it does not exist.
+	read(name string) ([]byte, error)
+	// write creates or replaces the named file.
+	write(name string, data []byte) error
+	// remove deletes the named file.
+	remove(name string) error
+	// path returns the path of the named file, for messages.
+	path(name string) string
+	// readPending returns the pending version of the named file, written by
+	// -update=pending, or an error wrapping fs.ErrNotExist if it has none.
+	readPending(name string) ([]byte, error)
+	// writePending creates or replaces the pending version of the named file.
+	writePending(name string, data []byte) error
+	// removePending deletes the pending version of the named file, if any.
+	removePending(name string) error
+}
+
+// dirFiles is a directory of files in an fs.FS.
+type dirFiles struct {
+	fsys fs.FS
+	dir  string
+}
+
+func (d dirFiles) names() ([]string, error) {
+	entries, err := fs.ReadDir(d.fsys, d.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (d dirFiles) has(name string) bool {
+	_, err := fs.Stat(d.fsys, d.name(name))
+	return err == nil
+}
+
+func (d dirFiles) read(name string) ([]byte, error) {
+	return fs.ReadFile(d.fsys, d.name(name))
+}
+
+func (d dirFiles) write(name string, data []byte) error {
+	return writeFile(d.fsys, d.name(name), data)
+}
+
+func (d dirFiles) remove(name string) error {
+	return removeFile(d.fsys, d.name(name))
+}
+
+func (d dirFiles) path(name string) string {
+	return displayPath(d.fsys, d.name(name))
+}
+
+// name returns the name in the fs.FS of the named file in the directory.
+func (d dirFiles) name(name string) string {
+	return path.Join(d.dir, name)
+}
+
+// The pending version of a file in a directory is the file next to it with
+// PendingExt appended to its name.
+
+func (d dirFiles) readPending(name string) ([]byte, error) {
+	return d.read(name + PendingExt)
+}
+
+func (d dirFiles) writePending(name string, data []byte) error {
+	return d.write(name+PendingExt, data)
+}
+
+func (d dirFiles) removePending(name string) error {
+	if !d.has(name + PendingExt) {
+		return nil
+	}
+	return d.remove(name + PendingExt)
+}
+
+// archiveFiles is a txtar archive whose sections are the files of a test case.
+// Writing or removing a section rewrites the archive, keeping its comment and
+// the order of its other sections.
+//
+// txtar ends every section with a newline, so a section's data is stored with
+// an extra newline that is dropped when it is read back. Files round trip
+// exactly, and hand-written sections need no trailing blank line.
+type archiveFiles struct {
+	fsys    fs.FS
+	file    string
+	archive *txtar.Archive
+}
+
+// archiveMarker matches lines that txtar would read as a section marker.
+var archiveMarker = regexp.MustCompile(`(?m)^-- .* --$`)
+
+// openArchive parses the txtar archive named file in fsys.
+func openArchive(fsys fs.FS, file string) (*archiveFiles, error) {
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	archive := txtar.Parse(data)
+	seen := map[string]bool{}
+	for _, f := range archive.Files {
+		if seen[f.Name] {
+			return nil, fmt.Errorf("duplicate section %s in %s", f.Name, displayPath(fsys, file))
+		}
+		seen[f.Name] = true
+	}
+	return &archiveFiles{fsys: fsys, file: file, archive: archive}, nil
+}
+
+func (a *archiveFiles) names() ([]string, error) {
+	var names []string
+	for _, f := range a.archive.Files {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (a *archiveFiles) has(name string) bool {
+	return a.index(name) >= 0
+}
+
+func (a *archiveFiles) read(name string) ([]byte, error) {
+	i := a.index(name)
+	if i < 0 {
+		return nil, &fs.PathError{Op: "read", Path: a.path(name), Err: fs.ErrNotExist}
+	}
+	return bytes.TrimSuffix(a.archive.Files[i].Data, []byte("\n")), nil
+}
+
+func (a *archiveFiles) write(name string, data []byte) error {
+	if archiveMarker.Match(data) {
+		return fmt.Errorf("cannot store %s in %s: it contains a line that looks like a txtar section marker", name, displayPath(a.fsys, a.file))
+	}
+	section := txtar.File{Name: name, Data: append(slices.Clip(data), '\n')}
+	if i := a.index(name); i >= 0 {
+		a.archive.Files[i] = section
+	} else {
+		i = a.insertionIndex(name)
+		a.archive.Files = slices.Insert(a.archive.Files, i, section)
+	}
+	return writeFile(a.fsys, a.file, txtar.Format(a.archive))
+}
+
+func (a *archiveFiles) remove(name string) error {
+	i := a.index(name)
+	if i < 0 {
+		return &fs.PathError{Op: "remove", Path: a.path(name), Err: fs.ErrNotExist}
+	}
+	a.archive.Files = slices.Delete(a.archive.Files, i, i+1)
+	return writeFile(a.fsys, a.file, txtar.Format(a.archive))
+}
+
+func (a *archiveFiles) path(name string) string {
+	return filepath.Join(displayPath(a.fsys, a.file), name)
+}
+
+// The pending versions of the sections of an archive are the sections of the
+// same name in a second archive next to it, with PendingExt appended to its
+// name, so that the archive itself only changes once they are accepted.
+
+func (a *archiveFiles) readPending(name string) ([]byte, error) {
+	pending, err := a.pendingArchive()
+	if err != nil {
+		return nil, err
+	}
+	return pending.read(name)
+}
+
+func (a *archiveFiles) writePending(name string, data []byte) error {
+	pending, err := a.pendingArchive()
+	if err != nil {
+		return err
+	}
+	return pending.write(name, data)
+}
+
+func (a *archiveFiles) removePending(name string) error {
+	pending, err := a.pendingArchive()
+	if err != nil || !pending.has(name) {
+		return err
+	}
+	if len(pending.archive.Files) == 1 {
+		return removeFile(a.fsys, pending.file)
+	}
+	return pending.remove(name)
+}
+
+// pendingArchive returns the archive of the pending versions of the sections
+// of a, which is empty if it does not exist yet.
+func (a *archiveFiles) pendingArchive() (*archiveFiles, error) {
+	file := a.file + PendingExt
+	pending, err := openArchive(a.fsys, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &archiveFiles{fsys: a.fsys, file: file, archive: &txtar.Archive{}}, nil
+	}
+	return pending, err
+}
+
+// index returns the index of the named section, or -1.
+func (a *archiveFiles) index(name string) int {
+	return slices.IndexFunc(a.archive.Files, func(f txtar.File) bool { return f.Name == name })
+}
+
+// insertionIndex returns where a new section belongs: after the last section
+// of the same step or case, such as 2.out.textpb after 2.in.textpb, or at the
+// end of the archive.
+func (a *archiveFiles) insertionIndex(name string) int {
+	prefix, _, _ := strings.Cut(name, ".")
+	for i := len(a.archive.Files) - 1; i >= 0; i-- {
+		if other, _, _ := strings.Cut(a.archive.Files[i].Name, "."); other == prefix {
+			return i + 1
+		}
+	}
+	return len(a.archive.Files)
+}
@@ -0,0 +1,183 @@
+package goldentest
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// memFS is a WritableFS held in memory, so tests can run and update golden
+// files without a temporary directory. It is not safe for concurrent writes.
+type memFS map[string]*fstest.MapFile
+
+func (m memFS) Open(name string) (fs.File, error) {
+	return fstest.MapFS(m).Open(name)
+}
+
+func (m memFS) WriteFile(name string, data []byte) error {
+	m[name] = &fstest.MapFile{Data: slices.Clone(data)}
+	return nil
+}
+
+func (m memFS) Remove(name string) error {
+	if _, ok := m[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m, name)
+	return nil
+}
+
+// contents returns an fs.FS with the given files, keyed by name.
+func contents(files map[string]string) memFS {
+	fsys := memFS{}
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	return fsys
+}
+
+func TestArchiveFiles(t *testing.T) {
+	fsys := contents(map[string]string{
+		"case.txtar": "Creates then reads a user.\n-- 1.in.hcl --\ncreate\n-- 2.in.hcl --\nget\n-- 2.out.json --\nold\n",
+	})
+
+	files, err := openArchive(fsys, "case.txtar")
+	if err != nil {
+		t.Fatalf("openArchive: %v", err)
+	}
+	if data, err := files.read("1.in.hcl"); err != nil || string(data) != "create" {
+		t.Errorf("read(1.in.hcl) = %q, %v, want %q", data, err, "create")
+	}
+	for name, data := range map[string]string{
+		"1.out.json": "no trailing newline",
+		"2.out.json": "trailing newline\n",
+	} {
+		if err := files.write(name, []byte(data)); err != nil {
+			t.Fatalf("write(%s): %v", name, err)
+		}
+	}
+
+	reopened, err := openArchive(fsys, "case.txtar")
+	if err != nil {
+		t.Fatalf("openArchive: %v", err)
+	}
+	if data, _ := reopened.read("1.out.json"); string(data) != "no trailing newline" {
+		t.Errorf("read(1.out.json) = %q, want %q", data, "no trailing newline")
+	}
+	if data, _ := reopened.read("2.out.json"); string(data) != "trailing newline\n" {
+		t.Errorf("read(2.out.json) = %q, want %q", data, "trailing newline\n")
+	}
+
+	want := "Creates then reads a user.\n" +
+		"-- 1.in.hcl --\ncreate\n" +
+		"-- 1.out.json --\nno trailing newline\n" +
+		"-- 2.in.hcl --\nget\n" +
+		"-- 2.out.json --\ntrailing newline\n\n"
+	if diff := unifiedDiff("case.txtar", []byte(want), fsys["case.txtar"].Data, false); diff != "" {
+		t.Errorf("archive mismatch:\n%s", diff)
+	}
+
+	if err := reopened.write("3.out.json", []byte("a\n-- 4.in.hcl --\nb")); err == nil {
+		t.Error("write accepted data containing a section marker")
+	}
+}
+
+func TestRunStepTestsArchive(t *testing.T) {
+	defer func(update UpdateFlag) { *Update = update }(*Update)
+
+	fsys := contents(map[string]string{
+		"directory_case/1.in.hcl":   "one",
+		"directory_case/1.out.json": "ONE",
+		"archive_case.txtar":        "The comment is kept.\n-- 1.in.hcl --\none\n-- 1.out.json --\nstale\n-- 2.in.hcl --\ntwo\n",
+	})
+
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+			return strings.ToUpper(string(stepFile.Data)), nil
+		},
+	}
+
+	*Update = UpdateFlag{Mode: UpdateAll}
+	config.RunTestsFS(t, fsys, ".")
+
+	want := "The comment is kept.\n-- 1.in.hcl --\none\n-- 1.out.json --\nONE\n-- 2.in.hcl --\ntwo\n-- 2.out.json --\nTWO\n"
+	if diff := unifiedDiff("archive_case.txtar", []byte(want), fsys["archive_case.txtar"].Data, false); diff != "" {
+		t.Errorf("updated archive mismatch:\n%s", diff)
+	}
+
+	*Update = UpdateFlag{}
+	config.RunTestsFS(t, fsys, ".")
+}
+
+func TestStepCasesConflict(t *testing.T) {
+	fsys := contents(map[string]string{
+		"flow/1.in.hcl": "",
+		"flow.txtar":    "-- 1.in.hcl --\n",
+	})
+
+	_, err := (&TestConfig[string, struct{}]{}).stepCases(fsys, ".")
+	if err == nil || !strings.Contains(err.Error(), "test case flow is defined by both flow and flow.txtar") {
+		t.Errorf("stepCases error = %v, want a conflict between flow and flow.txtar", err)
+	}
+}
+
+func TestRunTestsFSSubdirectory(t *testing.T) {
+	fsys := fstest.MapFS{
+		"testdata/upper/1.in.hcl":   {Data: []byte("one")},
+		"testdata/upper/1.out.json": {Data: []byte("ONE")},
+		"testdata/notes.md":         {Data: []byte("not a test case")},
+	}
+
+	var paths []string
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+			paths = append(paths, stepFile.FilePath)
+			return strings.ToUpper(string(stepFile.Data)), nil
+		},
+	}
+	config.RunTestsFS(t, fsys, "testdata")
+
+	if want := []string{"testdata/upper/1.in.hcl"}; !slices.Equal(paths, want) {
+		t.Errorf("step file paths = %q, want %q", paths, want)
+	}
+}
+
+func TestWriteFileReadOnly(t *testing.T) {
+	err := writeFile(fstest.MapFS{}, "case/1.out.json", []byte("data"))
+	if err == nil || !strings.Contains(err.Error(), "read-only fstest.MapFS") {
+		t.Errorf("writeFile error = %v, want a read-only error", err)
+	}
+	err = removeFile(fstest.MapFS{}, "case/1.out.json")
+	if err == nil || !strings.Contains(err.Error(), "read-only fstest.MapFS") {
+		t.Errorf("removeFile error = %v, want a read-only error", err)
+	}
+}
+
+func TestDirFS(t *testing.T) {
+	dir := t.TempDir()
+	fsys := DirFS(dir)
+	if err := fsys.WriteFile("1.out.json", []byte("data")); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "1.out.json")); err != nil || string(data) != "data" {
+		t.Errorf("file contains %q, %v, want %q", data, err, "data")
+	}
+	if got, want := displayPath(fsys, "1.out.json"), filepath.Join(dir, "1.out.json"); got != want {
+		t.Errorf("displayPath = %q, want %q", got, want)
+	}
+	if err := fsys.Remove("1.out.json"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := fs.Stat(fsys, "1.out.json"); !os.IsNotExist(err) {
+		t.Errorf("Stat after Remove = %v, want not exist", err)
+	}
+}
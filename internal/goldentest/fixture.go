@@ -0,0 +1,108 @@
+package goldentest
+
+import (
+	"sync"
+	"testing"
+)
+
+// fixturePool holds the fixtures created by SetUpSuite. A fixture is idle
+// when no test case is using it.
+type fixturePool[F any] struct {
+	mu   sync.Mutex
+	idle []F
+	all  []F
+}
+
+// get returns an idle fixture, if there is one.
+func (p *fixturePool[F]) get() (F, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var fixture F
+	if len(p.idle) == 0 {
+		return fixture, false
+	}
+	fixture = p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return fixture, true
+}
+
+// add records a newly created fixture so that it is torn down with the suite.
+func (p *fixturePool[F]) add(fixture F) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.all = append(p.all, fixture)
+}
+
+// put makes fixture available to other test cases.
+func (p *fixturePool[F]) put(fixture F) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idle = append(p.idle, fixture)
+}
+
+// acquireFixture returns the fixture for the test case name and a function to
+// call once the test case is done with it. reuse is false if the fixture may
+// still be in use, e.g. by a step that hung.
+//
+// With per-case fixtures, the fixture is created by SetUp and release calls
+// TearDown. With suite fixtures, an idle fixture is reset and reused, or a new
+// one is created by SetUpSuite if every fixture is in use, and release returns
+// it to the pool if reuse is set. Otherwise it is left out of the pool, and
+// only torn down with the rest of the suite.
+func (r *runner[T, F]) acquireFixture(t *testing.T, name string) (F, func(reuse bool)) {
+	config := r.config
+	var fixture F
+
+	if config.SetUpSuite == nil {
+		if config.SetUp != nil {
+			var err error
+			fixture, err = config.SetUp(t)
+			if err != nil {
+				t.Fatalf("SetUp failed for %s: %v", name, err)
+			}
+		}
+		return fixture, func(bool) {
+			if config.TearDown != nil {
+				if err := config.TearDown(t, fixture); err != nil {
+					t.Errorf("TearDown failed for %s: %v", name, err)
+				}
+			}
+		}
+	}
+
+	fixture, reused := r.pool.get()
+	if reused && config.Reset != nil {
+		if err := config.Reset(t, fixture); err != nil {
+			// The fixture is in an unknown state; leave it out of the pool
+			// so no other test case uses it. It is still torn down with the
+			// rest of the suite.
+			t.Fatalf("Reset failed for %s: %v", name, err)
+		}
+	}
+	if !reused {
+		var err error
+		fixture, err = config.SetUpSuite(r.suiteCtx)
+		if err != nil {
+			t.Fatalf("SetUpSuite failed for %s: %v", name, err)
+		}
+		r.pool.add(fixture)
+	}
+	return fixture, func(reuse bool) {
+		if reuse {
+			r.pool.put(fixture)
+		}
+	}
+}
+
+// tearDownSuite tears down every fixture created by SetUpSuite, reporting
+// failures to t, the testing.T of the RunTests call.
+func (r *runner[T, F]) tearDownSuite(t *testing.T) {
+	if r.config.TearDownSuite == nil {
+		return
+	}
+	for _, fixture := range r.pool.all {
+		if err := r.config.TearDownSuite(fixture); err != nil {
+			t.Errorf("TearDownSuite failed: %v", err)
+		}
+	}
+}
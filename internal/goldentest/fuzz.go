@@ -0,0 +1,313 @@
+package goldentest
+
+import (
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// AddSeeds adds the content of every input file with the extension ext in the
+// testdata tree at dir, such as ".hcl" or ".in.textpb", to the seed corpus of
+// f. Inputs are read from nested directories and from the sections of txtar
+// archives; output and metadata files are skipped. The fuzz target takes a
+// single []byte argument.
+//
+// Example:
+//
+//	func FuzzParseConfig(f *testing.F) {
+//		goldentest.AddSeeds(f, "testdata", ".hcl")
+//		f.Fuzz(func(t *testing.T, data []byte) {
+//			ParseConfig("fuzz.hcl", data)
+//		})
+//	}
+func AddSeeds(f *testing.F, dir, ext string) {
+	AddSeedsFS(f, DirFS(dir), ".", ext)
+}
+
+// AddSeedsFS is like AddSeeds, but reads the testdata tree at dir in fsys.
+func AddSeedsFS(f *testing.F, fsys fs.FS, dir, ext string) {
+	f.Helper()
+	seeds, err := inputSeeds(fsys, dir, ext)
+	if err != nil {
+		f.Fatalf("failed to read fuzz seeds: %v", err)
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+}
+
+// FuzzProto fuzzes fn with proto messages of type M. The seeds are the input
+// files with the extension ext in the testdata tree at dir, loaded with the
+// Codec registered for the last element of ext, or prototext. Rather than
+// mutating the bytes of inputs, which mostly produces inputs that do not
+// parse, the fuzzer picks a seed message by index and mutates the bytes that
+// MutateProto uses to perturb its fields.
+//
+// Example:
+//
+//	func FuzzGreet(f *testing.F) {
+//		goldentest.FuzzProto(f, "testdata", ".in.textpb", func(t *testing.T, in *pb.TestStepIn) {
+//			...
+//		})
+//	}
+func FuzzProto[M proto.Message](f *testing.F, dir, ext string, fn func(t *testing.T, msg M)) {
+	f.Helper()
+	seeds, err := inputSeeds(DirFS(dir), ".", ext)
+	if err != nil {
+		f.Fatalf("failed to read fuzz seeds: %v", err)
+	}
+	if len(seeds) == 0 {
+		f.Fatalf("no input files with extension %s in %s to use as fuzz seeds", ext, dir)
+	}
+	codec, ok := LookupCodec(path.Ext(ext))
+	if !ok {
+		codec = ProtoTextCodec
+	}
+	load := CodecLoader[M](codec)
+	msgs := make([]M, len(seeds))
+	for i, seed := range seeds {
+		if msgs[i], err = load(seed); err != nil {
+			f.Fatalf("failed to load fuzz seed %q: %v", seed, err)
+		}
+		f.Add(uint(i), []byte(nil))
+	}
+
+	f.Fuzz(func(t *testing.T, seed uint, mutations []byte) {
+		fn(t, MutateProto(msgs[seed%uint(len(msgs))], mutations))
+	})
+}
+
+// inputSeeds returns the contents of the input files with the extension ext
+// in the testdata tree at dir in fsys, in lexical order.
+func inputSeeds(fsys fs.FS, dir, ext string) ([][]byte, error) {
+	var seeds [][]byte
+	err := fs.WalkDir(fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		var files caseFiles = dirFiles{fsys: fsys, dir: path.Dir(name)}
+		names := []string{entry.Name()}
+		if strings.HasSuffix(name, ArchiveExt) {
+			archive, err := openArchive(fsys, name)
+			if err != nil {
+				return err
+			}
+			files = archive
+			if names, err = archive.names(); err != nil {
+				return err
+			}
+		}
+		for _, name := range names {
+			if !isSeedInput(name, ext) {
+				continue
+			}
+			data, err := files.read(name)
+			if err != nil {
+				return err
+			}
+			seeds = append(seeds, data)
+		}
+		return nil
+	})
+	return seeds, err
+}
+
+// isSeedInput reports whether the file name is an input with the extension
+// ext, rather than an output or metadata file.
+func isSeedInput(name, ext string) bool {
+	return strings.HasSuffix(name, ext) &&
+		!strings.Contains(name, ".out.") &&
+		name != MetadataFile &&
+		!strings.HasSuffix(name, MetadataExt)
+}
+
+// MutateProto returns a copy of msg with its fields perturbed as directed by
+// data, which the fuzzer mutates: each mutation picks a field of msg or of a
+// message nested in it and clears it, sets it to a new value or changes its
+// current value. The same data always produces the same mutations.
+func MutateProto[M proto.Message](msg M, data []byte) M {
+	clone := proto.Clone(msg).(M)
+	e := &entropy{data: data}
+	for !e.empty() {
+		mutateField(clone.ProtoReflect(), e)
+	}
+	return clone
+}
+
+// entropy hands out the bytes that direct mutations. Once they run out, it
+// returns zeros.
+type entropy struct {
+	data []byte
+}
+
+func (e *entropy) empty() bool {
+	return len(e.data) == 0
+}
+
+func (e *entropy) byte() byte {
+	if e.empty() {
+		return 0
+	}
+	b := e.data[0]
+	e.data = e.data[1:]
+	return b
+}
+
+// intn returns a number in [0, n).
+func (e *entropy) intn(n int) int {
+	if n <= 256 {
+		return int(e.byte()) % n
+	}
+	return int(uint16(e.byte())<<8|uint16(e.byte())) % n
+}
+
+// bytes returns up to 16 bytes.
+func (e *entropy) bytes() []byte {
+	n := e.intn(17)
+	b := make([]byte, 0, n)
+	for i := 0; i < n; i++ {
+		b = append(b, e.byte())
+	}
+	return b
+}
+
+// fieldRef is a field of a message that can be mutated.
+type fieldRef struct {
+	m  protoreflect.Message
+	fd protoreflect.FieldDescriptor
+}
+
+// mutableFields returns every field of m and of the messages set in it.
+func mutableFields(m protoreflect.Message) []fieldRef {
+	var refs []fieldRef
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		refs = append(refs, fieldRef{m, fd})
+		if !m.Has(fd) || fd.Message() == nil || fd.IsMap() {
+			continue
+		}
+		if fd.IsList() {
+			list := m.Get(fd).List()
+			for j := 0; j < list.Len(); j++ {
+				refs = append(refs, mutableFields(list.Get(j).Message())...)
+			}
+			continue
+		}
+		refs = append(refs, mutableFields(m.Get(fd).Message())...)
+	}
+	return refs
+}
+
+// mutateField applies one mutation to a field of m or of a message nested in
+// it.
+func mutateField(m protoreflect.Message, e *entropy) {
+	refs := mutableFields(m)
+	if len(refs) == 0 {
+		e.byte()
+		return
+	}
+	ref := refs[e.intn(len(refs))]
+	m, fd := ref.m, ref.fd
+	switch op := e.intn(3); {
+	case op == 0:
+		m.Clear(fd)
+	case fd.IsList():
+		list := m.Mutable(fd).List()
+		if op == 2 && list.Len() > 0 {
+			i := e.intn(list.Len())
+			list.Set(i, perturbValue(fd, list.Get(i), e))
+			return
+		}
+		list.Append(newValue(fd, list.NewElement(), e))
+	case fd.IsMap():
+		mp := m.Mutable(fd).Map()
+		key := newValue(fd.MapKey(), fd.MapKey().Default(), e).MapKey()
+		mp.Set(key, newValue(fd.MapValue(), mp.NewValue(), e))
+	case op == 2 && m.Has(fd):
+		m.Set(fd, perturbValue(fd, m.Get(fd), e))
+	case fd.Message() != nil:
+		// Setting a field of a oneof switches the oneof to it.
+		m.Set(fd, m.NewField(fd))
+	default:
+		m.Set(fd, newValue(fd, fd.Default(), e))
+	}
+}
+
+// Interesting values for numeric fields.
+var (
+	interestingInts   = []int64{0, 1, -1, math.MaxInt32, math.MinInt32, math.MaxInt64, math.MinInt64}
+	interestingUints  = []uint64{0, 1, math.MaxUint32, math.MaxUint64}
+	interestingFloats = []float64{0, -1, 0.5, math.MaxFloat64, math.SmallestNonzeroFloat64, math.Inf(1), math.Inf(-1), math.NaN()}
+)
+
+// newValue returns a new value for fd, or for an element of fd if it is a
+// list. zero is the value to start from for messages.
+func newValue(fd protoreflect.FieldDescriptor, zero protoreflect.Value, e *entropy) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(e.byte()&1 == 1)
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		if i := e.intn(values.Len() + 1); i < values.Len() {
+			return protoreflect.ValueOfEnum(values.Get(i).Number())
+		}
+		// An unknown enum number.
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(1000 + e.intn(1000)))
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(interestingInts[e.intn(len(interestingInts))]))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(interestingInts[e.intn(len(interestingInts))])
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(interestingUints[e.intn(len(interestingUints))]))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(interestingUints[e.intn(len(interestingUints))])
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(float32(interestingFloats[e.intn(len(interestingFloats))]))
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(interestingFloats[e.intn(len(interestingFloats))])
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(strings.ToValidUTF8(string(e.bytes()), "�"))
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes(e.bytes())
+	default:
+		return zero
+	}
+}
+
+// perturbValue returns a value close to v, the value of fd or of an element of
+// fd if it is a list: a string or bytes value is truncated or extended, and a
+// number is nudged.
+func perturbValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, e *entropy) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		s := v.String()
+		if e.byte()&1 == 0 {
+			return protoreflect.ValueOfString(strings.ToValidUTF8(s[:e.intn(len(s)+1)], ""))
+		}
+		return protoreflect.ValueOfString(strings.ToValidUTF8(s+string(e.bytes()), "�"))
+	case protoreflect.BytesKind:
+		b := v.Bytes()
+		if e.byte()&1 == 0 {
+			return protoreflect.ValueOfBytes(b[:e.intn(len(b)+1)])
+		}
+		return protoreflect.ValueOfBytes(append(append([]byte(nil), b...), e.bytes()...))
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(!v.Bool())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(v.Int()) + int32(int8(e.byte())))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(v.Int() + int64(int8(e.byte())))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(v.Uint()) + uint32(e.byte()))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(v.Uint() + uint64(e.byte()))
+	default:
+		return newValue(fd, v, e)
+	}
+}
@@ -0,0 +1,72 @@
+package goldentest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestInputSeeds(t *testing.T) {
+	fsys := contents(map[string]string{
+		"simple.hcl":              "one-shot",
+		"simple.out.json":         "output",
+		"simple.meta.hcl":         "metadata",
+		"greet/basic/1.in.hcl":    "step 1",
+		"greet/basic/1.out.json":  "output",
+		"greet/basic/2.in.hcl":    "step 2",
+		"greet/basic/case.hcl":    "metadata",
+		"greet/retry.txtar":       "-- case.hcl --\nmetadata\n-- 1.in.hcl --\narchived\n-- 1.out.json --\noutput\n",
+		"greet/retry.txtar.notes": "notes",
+	})
+
+	seeds, err := inputSeeds(fsys, ".", ".hcl")
+	if err != nil {
+		t.Fatalf("inputSeeds: %v", err)
+	}
+	var got []string
+	for _, seed := range seeds {
+		got = append(got, string(seed))
+	}
+	want := []string{"step 1", "step 2", "archived", "one-shot"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("seeds mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMutateProto(t *testing.T) {
+	msg := &descriptorpb.DescriptorProto{
+		Name: proto.String("User"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			{Name: proto.String("name"), Number: proto.Int32(1)},
+		},
+	}
+	original := proto.Clone(msg)
+
+	if diff := cmp.Diff(msg, MutateProto(msg, nil), protocmp.Transform()); diff != "" {
+		t.Errorf("MutateProto without data changed the message (-want +got):\n%s", diff)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	changed := 0
+	for i := 0; i < 1000; i++ {
+		data := make([]byte, rng.Intn(64))
+		rng.Read(data)
+		first, second := MutateProto(msg, data), MutateProto(msg, data)
+		if diff := cmp.Diff(first, second, protocmp.Transform()); diff != "" {
+			t.Fatalf("MutateProto(%x) is not deterministic (-first +second):\n%s", data, diff)
+		}
+		if !proto.Equal(first, msg) {
+			changed++
+		}
+	}
+	if changed < 900 {
+		t.Errorf("MutateProto changed the message for %d of 1000 inputs, want most of them", changed)
+	}
+	if diff := cmp.Diff(original, msg, protocmp.Transform()); diff != "" {
+		t.Errorf("MutateProto modified its input (-want +got):\n%s", diff)
+	}
+}
@@ -32,6 +32,9 @@
 //		},
 //		config.RunTests(t, "testdata")
 //
+// RunTestsFS reads test files from an fs.FS instead, such as an embed.FS or
+// an fstest.MapFS built in code. -update needs a WritableFS such as DirFS.
+//
 // # One-Shot Tests
 //
 // For one-shot tests, set TestOneShotFunc. Each input file (e.g., "example.hcl")
@@ -44,6 +47,17 @@
 // For step tests, set StepTestFunc. Input files are numbered sequentially
 // (1.hcl, 2.hcl, etc.) within subdirectories, and each step's output is
 // compared against corresponding output files (1.out.json, 2.out.json, etc.).
+// Each step runs as a subtest named step_N, so
+// -run 'TestGolden/simple_flow/step_2' checks only that step; the steps
+// before it still run unchecked, since it depends on them, and the steps
+// after it do not run.
+//
+// Any step may instead have an error output (2.out.txt), meaning the step is
+// expected to fail with that error; the following steps still run, so a
+// scenario can check that a request fails and that a retry succeeds. A step
+// that fails without an error output stops its test case. In a subdirectory
+// starting with ErrorPrefix, the final step is expected to fail unless it has
+// a success output.
 //
 //			config := &goldentest.TestConfig[*StepResult, *ServerFixture]{
 //				InputExt:         ".textpb",
@@ -68,6 +82,52 @@
 //		 }
 //	  config.RunTests(t, "testdata")
 //
+// A step can capture values from its result, such as the ID of a resource it
+// created, with a directive comment in its input file. Later steps reference
+// them as ${capture.name}, which is replaced before StepTestFunc sees the
+// input. Values are escaped for a double-quoted string, so references to
+// strings belong inside quotes. Capture resolves the path of each directive;
+// for protobuf results, use CaptureProtoField:
+//
+//	# capture user_id = rpc.create_user_response.user.name
+//
+// and in a later step:
+//
+//	rpc: { get_user_request: { name: "${capture.user_id}" } }
+//
+// # Fixtures
+//
+// SetUp and TearDown create and destroy a fixture for every test case. When
+// the fixture is expensive to create, set SetUpSuite instead: fixtures are
+// then created once, handed from one test case to the next and destroyed by
+// TearDownSuite when RunTests completes. Reset, if set, cleans a fixture
+// before it is reused. Parallel test cases never share a fixture; the suite
+// creates as many as there are test cases running at once. Since fixtures
+// outlive the test case that creates them, SetUpSuite receives the context of
+// the RunTests call rather than a testing.T.
+//
+// # Metadata
+//
+// A test case can have a metadata file: case.hcl in the directory or archive
+// of a step test case, and example.meta.hcl next to example.hcl for a one-shot
+// test case. It skips the test case, tags it, replaces CaseTimeout for it and
+// passes parameters to SetUp, which reads them with Metadata. Suite fixtures
+// are shared by test cases, so with SetUpSuite a test case must not have
+// fixture parameters:
+//
+//	skip_short = true
+//	tags       = ["slow"]
+//	timeout    = "2m"
+//
+//	fixture {
+//	  user = "admin"
+//	}
+//
+// The -golden-tags flag runs only the test cases with one of the given tags
+// and none of the tags prefixed with "!":
+//
+//	go test -golden-tags=admin,!slow
+//
 // # Configuration Rules
 //
 // TestConfig must have exactly one of TestOneShotFunc or StepTestFunc set:
@@ -78,6 +138,23 @@
 //   - ErrorFunc and ErrorOutputExt must both be set or both unset
 //   - ErrorPrefix is optional and defaults to "error_" if ErrorFunc is set
 //   - If ErrorFunc is not set, tests that return errors will fail immediately
+//   - ErrorGolden can replace ErrorFunc to store errors as structured values
+//
+// # Structured Errors
+//
+// ErrorFunc reduces errors to bytes that must match byte for byte. ErrorGolden
+// stores them as values instead, loaded back and compared with cmp, so that
+// tests can ignore the wording of messages while asserting codes and source
+// ranges. StatusErrors stores gRPC errors as google.rpc.Status messages with
+// their typed details, DiagnosticErrors stores HCL diagnostics as a list of
+// Diagnostic, and TypedErrors stores any other type:
+//
+//	config.ErrorOutputExt = ".textpb"
+//	config.ErrorGolden = goldentest.StatusErrors(
+//		protocmp.IgnoreFields(&spb.Status{}, "message"),
+//	)
+//
+// Error outputs are formatted by the Codec registered for ErrorOutputExt.
 //
 // # File Organization
 //
@@ -94,37 +171,185 @@
 //	  ├── simple_flow/
 //	  │   ├── 1.in.textpb → 1.out.textpb
 //	  │   └── 2.in.textpb → 2.out.textpb
-//	  └── error_scenario/
+//	  └── retry_scenario/
 //	      ├── 1.in.textpb → 1.out.textpb
-//	      └── 2.in.textpb → 2.out.txt (error)
+//	      ├── 2.in.textpb → 2.out.txt (error)
+//	      └── 3.in.textpb → 3.out.textpb
+//
+// With Recursive, test cases can be organized in nested subdirectories, each
+// of which runs as a subtest grouping its test cases, e.g.
+// TestGolden/greet/unicode/emoji. In step mode, a directory containing step
+// input files is a test case and any other directory is a group:
+//
+//	testdata/
+//	  └── greet/
+//	      ├── ascii.txtar
+//	      └── unicode/
+//	          └── emoji/
+//	              ├── 1.in.textpb → 1.out.textpb
+//	              └── 2.in.textpb → 2.out.textpb
+//
+// A step test case can instead be a single txtar archive (see
+// golang.org/x/tools/txtar) named after the case, with a section for each
+// file. Archives and directories can be mixed in the same test directory:
+//
+//	testdata/retry_scenario.txtar:
+//	  Retries a request that failed.
+//	  -- 1.in.textpb --
+//	  ...
+//	  -- 1.out.textpb --
+//	  ...
+//	  -- 2.in.textpb --
+//	  ...
+//
+// -update rewrites the output sections of an archive in place, keeping its
+// comment and the order of its sections. Sections end with a newline that is
+// not part of their content.
+//
+// # Formats
+//
+// Unless Formatter and Loader are set, results are stored in the format of the
+// Codec registered for SuccessOutputExt:
+//
+//   - .txt: raw text, for strings, byte slices and encoding.TextMarshaler
+//   - .json: indented JSON, using protojson for proto messages
+//   - .yaml, .yml: YAML converted from that JSON
+//   - .textpb, .txtpb, .pbtxt: prototext
+//   - .binpb: a hex dump of the binary encoding of a proto message
+//
+// Other extensions use DefaultFormatter and DefaultLoader. RegisterCodec adds
+// or replaces the codec for an extension:
+//
+//	func init() {
+//		goldentest.RegisterCodec(".csv", csvCodec{})
+//	}
+//
+// # Scrubbing
+//
+// Scrubbers and TextScrubbers replace nondeterministic values, such as
+// timestamps, IDs or server addresses, with numbered placeholders before
+// results are compared. Placeholders are numbered per test case, so a value
+// that appears in several steps gets the same placeholder in each:
+//
+//	config.Scrubbers = []goldentest.Scrubber[*pb.Response]{
+//		goldentest.ScrubProtoTimestamps[*pb.Response](),
+//	}
+//	config.TextScrubbers = []goldentest.TextScrubber{goldentest.ScrubUUIDs}
+//
+// # Fuzzing
+//
+// Golden inputs double as a seed corpus for native Go fuzz targets. AddSeeds
+// adds every input file of a testdata tree to a fuzz target that takes a
+// []byte, and FuzzProto fuzzes a function of proto inputs by perturbing the
+// fields of the seed messages:
+//
+//	func FuzzGreet(f *testing.F) {
+//		goldentest.FuzzProto(f, "testdata", ".in.textpb", func(t *testing.T, in *pb.TestStepIn) {
+//			...
+//		})
+//	}
+//
+// # Benchmarks
+//
+// RunBenchmarks replays the same test cases through the test function without
+// comparing results, reporting ns/op and allocations for each case. A step
+// case is benchmarked as its whole sequence of steps, with a fresh fixture for
+// each run. Benchmarks have no testing.T for SetUp or SetUpSuite, so their
+// fixtures are created by BenchSetUp instead:
+//
+//	config.BenchSetUp = func(b *testing.B) (*MyFixture, error) {
+//		return &MyFixture{Client: createClient()}, nil
+//	}
+//
+//	func BenchmarkGreet(b *testing.B) {
+//		config.RunBenchmarks(b, "testdata")
+//	}
+//
+// # Mismatches
+//
+// Results are compared with cmp, using DiffOpts. When a result does not match
+// its golden file, both are formatted with the Formatter and reported as a
+// unified diff with the golden file's path and line numbers. The diff is
+// colored when the output is a terminal, unless NO_COLOR is set.
 //
 // # Updating Golden Files
 //
 // Use the -update flag to regenerate expected output files:
 //
 //	go test -update ./path/to/tests
+//
+// A missing golden file fails the test with the command that creates it.
+// -update=missing creates missing golden files without rewriting existing ones:
+//
+//	go test -update=missing ./path/to/tests
+//
+// Glob patterns restrict updates to some test cases, and dry-run lists the
+// golden files that would change without writing them:
+//
+//	go test -v -update=multi_*,dry-run ./path/to/tests
+//
+// To review changes one by one instead of accepting them all, -update=pending
+// writes the result of every test case that does not match next to its golden
+// file, with PendingExt appended to its name, and still fails the test case.
+// The review command then shows the diff of each pending file and accepts or
+// rejects it:
+//
+//	go test ./path/to/tests -update=pending
+//	go run ./internal/goldentest/cmd/review
+//
+// In CI, -golden-check fails tests whose golden files match but are not
+// exactly what the Formatter produces, such as hand-edited files.
+// Output files that no test case reads, such as the output of a deleted input
+// or a success output next to the error output of the same case, fail the
+// test. -update deletes them.
+//
+// # Reports
+//
+// -golden-report, or the GOLDEN_REPORT environment variable, names a file to
+// write a report of every test case and step to, with its status, golden file,
+// diff, duration and whether -update rewrote it. The report is JUnit XML if
+// the name ends in .xml, and JSON otherwise. An absolute name gets the
+// package added before its extension, since the packages of go test ./...
+// would otherwise overwrite each other's report:
+//
+//	go test ./path/to/tests -golden-report=golden-report.xml
 package goldentest
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
-	"os"
+	"io/fs"
+	"path"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
-	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/testing/protocmp"
 )
 
-// Update is a flag that controls whether golden files should be updated
-var Update = flag.Bool("update", false, "update .out files if there is a difference")
+// Update is a flag that controls whether golden files should be updated.
+// -update rewrites golden files that differ and creates missing ones;
+// -update=missing only creates missing ones; -update=pending writes pending
+// versions to review instead. See UpdateFlag for selecting test cases and dry
+// runs.
+var Update = new(UpdateFlag)
+
+// GoldenCheck is a flag that makes tests fail if a golden file matches but is
+// not byte-for-byte what the Formatter produces, e.g. because it was edited
+// by hand. Use it in CI; -update together with -golden-check reformats them.
+var GoldenCheck = flag.Bool("golden-check", false, "fail if a golden file is not formatted by the test's Formatter")
+
+func init() {
+	flag.Var(Update, "update", "update .out files: 'all' (or no value) rewrites files that differ, 'missing' only creates absent files, 'pending' writes .new files to review; "+
+		"add 'dry-run' to only list them and glob patterns to select test cases, e.g. -update=multi_*,dry-run")
+}
 
 // SetUpFunc creates a fixture for a test case. The fixture is passed to all test functions
 // within the same test case and is created fresh for each test case.
@@ -163,6 +388,55 @@ type SetUpFunc[F any] func(t *testing.T) (F, error)
 //	}
 type TearDownFunc[F any] func(t *testing.T, fixture F) error
 
+// ResetFunc returns a suite fixture to a clean state before it is reused by
+// another test case, e.g. by truncating tables or clearing caches.
+//
+// Parameters:
+//   - t: The testing.T instance for the test case about to use the fixture
+//   - fixture: The fixture that was created by SetUpSuite
+//
+// Returns:
+//   - error: Error if the fixture cannot be reset (fails the test case and
+//     discards the fixture, so later test cases get a fresh one)
+//
+// Example:
+//
+//	config.Reset = func(t *testing.T, fixture *ServerFixture) error {
+//		return fixture.Store.Truncate(t.Context())
+//	}
+type ResetFunc[F any] func(t *testing.T, fixture F) error
+
+// SuiteSetUpFunc creates a fixture that is shared by the test cases of a
+// RunTests call. It may be called from any test case, so it gets no
+// testing.T; ctx is the context of the RunTests call, which is canceled once
+// every test case has completed, just before TearDownSuite runs.
+//
+// Example:
+//
+//	config.SetUpSuite = func(ctx context.Context) (*ServerFixture, error) {
+//		return startTestServer(ctx)
+//	}
+type SuiteSetUpFunc[F any] func(ctx context.Context) (F, error)
+
+// SuiteTearDownFunc cleans up a fixture created by SuiteSetUpFunc once every
+// test case has completed. Its error is reported as a failure of the RunTests
+// call.
+type SuiteTearDownFunc[F any] func(fixture F) error
+
+// BenchSetUpFunc creates a fixture for a benchmark of a test case. It is the
+// counterpart of SetUpFunc and SetUpSuite for RunBenchmarks, which has no
+// testing.T to pass them.
+//
+// Example:
+//
+//	config.BenchSetUp = func(b *testing.B) (*ServerFixture, error) {
+//		return startTestServer(b.Context())
+//	}
+type BenchSetUpFunc[F any] func(b *testing.B) (F, error)
+
+// BenchTearDownFunc cleans up a fixture created by BenchSetUpFunc.
+type BenchTearDownFunc[F any] func(b *testing.B, fixture F) error
+
 // TestConfig holds configuration for golden file testing.
 //
 // TestConfig is generic over the result type T that test functions return and
@@ -175,6 +449,8 @@ type TearDownFunc[F any] func(t *testing.T, fixture F) error
 //   - Formatter: Converts result values to bytes for golden file storage
 //   - Loader: Converts bytes from golden files back to result values for comparison
 //   - DiffOpts: Additional options for cmp.Diff (e.g., protocmp.Transform() for protobuf)
+//   - Scrubbers: Replace nondeterministic values in results with placeholders
+//   - TextScrubbers: Replace regexp matches in formatted results and errors with placeholders
 //
 // Test function fields (exactly one must be set):
 //   - TestOneShotFunc: For one-shot tests, processes individual input files
@@ -184,8 +460,32 @@ type TearDownFunc[F any] func(t *testing.T, fixture F) error
 //   - SetUp: Creates fixture for each test case (if nil, zero value is used)
 //   - TearDown: Cleans up fixture after test case (if nil, no cleanup)
 //
+// Suite fixture fields (optional, mutually exclusive with SetUp and TearDown):
+//   - SetUpSuite: Creates a fixture once and shares it between test cases
+//   - TearDownSuite: Cleans up suite fixtures after all test cases complete
+//   - Reset: Cleans a suite fixture before it is reused by another test case
+//
+// Benchmark fixture fields (optional, required by RunBenchmarks if SetUp or SetUpSuite is set):
+//   - BenchSetUp: Creates a fixture for a benchmark of a test case
+//   - BenchTearDown: Cleans up a fixture created by BenchSetUp
+//
+// Discovery fields (optional):
+//   - Recursive: Runs test cases in subdirectories as nested groups
+//
+// Parallelism fields (optional):
+//   - Parallel: Runs test cases in parallel with each other
+//   - MaxParallelism: Limits how many test cases run at once (0 means no extra limit)
+//
+// Timeout fields (optional, step tests only):
+//   - StepTimeout: Deadline for each step's context
+//   - CaseTimeout: Deadline for the context of a whole step test case
+//
+// Capture fields (optional, step tests only):
+//   - Capture: Resolves the values that steps capture for later steps
+//
 // Error handling fields (ErrorFunc and ErrorOutputExt must both be set or both unset):
 //   - ErrorFunc: Converts errors to byte representation for comparison
+//   - ErrorGolden: Stores errors as structured values instead of ErrorFunc
 //   - ErrorPrefix: Prefix to identify error test case files (defaults to "error_")
 //   - ErrorOutputExt: Extension for error output files (e.g., ".txt")
 //
@@ -207,11 +507,13 @@ type TestConfig[T, F any] struct {
 	SuccessOutputExt string
 
 	// Formatter converts result values to bytes for golden file storage.
-	// If not set, DefaultFormatter[T]() will be used.
+	// If not set, the Codec registered for SuccessOutputExt is used, or
+	// DefaultFormatter[T]() if there is none.
 	Formatter Formatter[T]
 
 	// Loader converts bytes from golden files back to result values for comparison.
-	// If not set, DefaultLoader[T]() will be used.
+	// If not set, the Codec registered for SuccessOutputExt is used, or
+	// DefaultLoader[T]() if there is none.
 	Loader Loader[T]
 
 	// DiffOpts are additional options passed to cmp.Diff for comparing values.
@@ -219,6 +521,15 @@ type TestConfig[T, F any] struct {
 	// If not set, only cmpopts.EquateEmpty() will be used.
 	DiffOpts []cmp.Option
 
+	// Scrubbers replace nondeterministic values, such as timestamps or generated IDs, in
+	// results before they are compared and formatted. They run in order, before TextScrubbers.
+	Scrubbers []Scrubber[T]
+
+	// TextScrubbers replace regexp matches in formatted results and in error outputs with
+	// numbered placeholders. Results are formatted, scrubbed and loaded back before they are
+	// compared, so placeholders must be loadable, e.g. inside strings.
+	TextScrubbers []TextScrubber
+
 	// SetUp creates a fixture for each test case. The fixture is shared across all steps
 	// in a step test, but created fresh for each test case. If nil, the zero value of F is used.
 	SetUp SetUpFunc[F]
@@ -227,6 +538,55 @@ type TestConfig[T, F any] struct {
 	// If nil, no cleanup is performed.
 	TearDown TearDownFunc[F]
 
+	// SetUpSuite creates a fixture that is shared by the test cases of a RunTests call,
+	// for resources that are expensive to create such as a server or a seeded datastore.
+	// It receives the context of the RunTests call, which outlives individual test cases.
+	// When Parallel is set, a pool of fixtures is created on demand, one for each test
+	// case running at the same time. Must not be set together with SetUp.
+	SetUpSuite SuiteSetUpFunc[F]
+
+	// TearDownSuite cleans up every fixture created by SetUpSuite once all test cases
+	// have completed. If nil, no cleanup is performed.
+	TearDownSuite SuiteTearDownFunc[F]
+
+	// Reset is called before a suite fixture is handed to a test case other than the
+	// first one to use it. If nil, fixtures are reused as they are.
+	Reset ResetFunc[F]
+
+	// BenchSetUp creates the fixture of each run of a benchmark by RunBenchmarks, which
+	// uses it instead of SetUp or SetUpSuite. It must be set if either of them is.
+	BenchSetUp BenchSetUpFunc[F]
+
+	// BenchTearDown cleans up a fixture created by BenchSetUp. If nil, no cleanup is
+	// performed.
+	BenchTearDown BenchTearDownFunc[F]
+
+	// Recursive discovers test cases in subdirectories of the test directory, at any depth,
+	// and runs each subdirectory as a subtest grouping the test cases in it. In step mode, a
+	// directory that contains step input files is a test case and any other is a group.
+	Recursive bool
+
+	// Parallel makes every test case call t.Parallel, so cases run concurrently with each
+	// other (and with other parallel tests). Steps within a step test case always run in order.
+	Parallel bool
+
+	// MaxParallelism limits how many test cases of this config run at the same time when
+	// Parallel is set. Zero means cases are only limited by the -test.parallel flag.
+	MaxParallelism int
+
+	// StepTimeout is the deadline of the context passed to StepTestFunc for each step.
+	// A step that exceeds it fails its test case. Zero means no per-step deadline.
+	StepTimeout time.Duration
+
+	// CaseTimeout is the deadline of the context shared by all the steps of a test case.
+	// Zero means the case is only limited by the -test.timeout flag.
+	CaseTimeout time.Duration
+
+	// Capture resolves the path of each "# capture name = path" directive in a step input
+	// against the step's result. Later steps of the test case reference the value as
+	// ${capture.name}. Must be set if any step declares a capture.
+	Capture CaptureFunc[T]
+
 	// TestOneShotFunc processes input data for one-shot tests. Set this for single-file golden tests.
 	// Must not be set if StepTestFunc is set.
 	TestOneShotFunc TestOneShotFunc[T, F]
@@ -239,6 +599,11 @@ type TestConfig[T, F any] struct {
 	// Must be set together with ErrorOutputExt, or left nil if ErrorOutputExt is unset.
 	// If error handling is disabled, tests that return errors will fail immediately.
 	ErrorFunc ErrorFunc
+
+	// ErrorGolden stores errors as structured values compared with cmp, such as
+	// StatusErrors or DiagnosticErrors, instead of the bytes of ErrorFunc.
+	// Must not be set together with ErrorFunc; otherwise it is set like ErrorFunc.
+	ErrorGolden ErrorGolden
 }
 
 // TestOneShotFunc is a function that processes input data for one-shot golden tests.
@@ -307,35 +672,32 @@ type Formatter[T any] func(value T) ([]byte, error)
 //	}
 type Loader[T any] func(data []byte) (T, error)
 
+// protoTextFieldSeparator matches the separator after a field name in
+// multi-line prototext output, which prototext randomly widens to two spaces
+// so that its output is not relied on to be stable.
+var protoTextFieldSeparator = regexp.MustCompile(`(?m)^( *[\w.\[\]/]+:)  `)
+
+// stableProtoText removes the random extra spaces from multi-line prototext
+// output, so that golden files can be compared byte for byte.
+func stableProtoText(data []byte) []byte {
+	return protoTextFieldSeparator.ReplaceAll(data, []byte("$1 "))
+}
+
 // DefaultFormatter returns a default formatter that handles common types.
 //
 // The default formatter uses the following strategy:
 //   - string: Returns the string as bytes
 //   - []byte: Returns the bytes directly
-//   - proto.Message: Uses prototext marshaling with indentation
-//   - Everything else: Uses JSON marshaling with indentation
+//   - proto.Message: Uses ProtoTextCodec, prototext with indentation and
+//     without the random extra spaces prototext adds to discourage byte
+//     comparisons
+//   - Everything else: Uses JSONCodec, JSON with indentation
 //
 // This covers the most common use cases and provides a reasonable default
-// for most golden file testing scenarios.
+// for most golden file testing scenarios. RunTests only uses it when no codec
+// is registered for SuccessOutputExt.
 func DefaultFormatter[T any]() Formatter[T] {
-	return func(value T) ([]byte, error) {
-		// Handle the most common cases first
-		switch v := any(value).(type) {
-		case string:
-			return []byte(v), nil
-		case []byte:
-			return v, nil
-		case proto.Message:
-			// Use prototext for proto messages with nice formatting
-			return prototext.MarshalOptions{
-				Multiline: true,
-				Indent:    "  ",
-			}.Marshal(v)
-		default:
-			// Fall back to JSON for everything else
-			return json.MarshalIndent(value, "", "  ")
-		}
-	}
+	return CodecFormatter[T](defaultCodec[T]())
 }
 
 // DefaultLoader returns a default loader that handles common types.
@@ -348,32 +710,16 @@ func DefaultFormatter[T any]() Formatter[T] {
 //
 // This is symmetric to DefaultFormatter and handles the same type cases.
 func DefaultLoader[T any]() Loader[T] {
-	return func(data []byte) (T, error) {
-		var zero T
-
-		// Handle the most common cases first
-		switch any(zero).(type) {
-		case string:
-			return any(string(data)).(T), nil
-		case []byte:
-			return any(data).(T), nil
-		case proto.Message:
-			// Create a new instance of the proto message type using ProtoReflect
-			// Get the message descriptor from a zero value
-			var zeroPtr *T = new(T)
-			if msg, ok := any(*zeroPtr).(proto.Message); ok {
-				newMsg := msg.ProtoReflect().New().Interface()
-				err := prototext.Unmarshal(data, newMsg)
-				return any(newMsg).(T), err
-			}
-			return zero, fmt.Errorf("type %T does not implement proto.Message", zero)
-		default:
-			// Fall back to JSON for everything else
-			var result T
-			err := json.Unmarshal(data, &result)
-			return result, err
-		}
+	return CodecLoader[T](defaultCodec[T]())
+}
+
+// defaultCodec returns the codec of DefaultFormatter and DefaultLoader for T.
+func defaultCodec[T any]() Codec {
+	var zero T
+	if _, ok := any(zero).(proto.Message); ok {
+		return ProtoTextCodec
 	}
+	return JSONCodec
 }
 
 // inferDefaultOptions returns default configuration options based on the result type T.
@@ -475,12 +821,91 @@ func WithTearDown[T, F any](fn TearDownFunc[F]) ConfigOption[T, F] {
 	}
 }
 
+func WithSetUpSuite[T, F any](fn SuiteSetUpFunc[F]) ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.SetUpSuite = fn
+	}
+}
+
+func WithTearDownSuite[T, F any](fn SuiteTearDownFunc[F]) ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.TearDownSuite = fn
+	}
+}
+
+func WithReset[T, F any](fn ResetFunc[F]) ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.Reset = fn
+	}
+}
+
+func WithBenchSetUp[T, F any](fn BenchSetUpFunc[F]) ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.BenchSetUp = fn
+	}
+}
+
+func WithBenchTearDown[T, F any](fn BenchTearDownFunc[F]) ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.BenchTearDown = fn
+	}
+}
+
 func WithErrorHandling[T, F any](errorFunc ErrorFunc) ConfigOption[T, F] {
 	return func(c *TestConfig[T, F]) {
 		c.ErrorFunc = errorFunc
 	}
 }
 
+func WithErrorGolden[T, F any](errorGolden ErrorGolden) ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.ErrorGolden = errorGolden
+	}
+}
+
+func WithStepTimeout[T, F any](timeout time.Duration) ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.StepTimeout = timeout
+	}
+}
+
+func WithCaseTimeout[T, F any](timeout time.Duration) ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.CaseTimeout = timeout
+	}
+}
+
+func WithCapture[T, F any](fn CaptureFunc[T]) ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.Capture = fn
+	}
+}
+
+func WithRecursive[T, F any]() ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.Recursive = true
+	}
+}
+
+func WithParallel[T, F any](maxParallelism int) ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.Parallel = true
+		c.MaxParallelism = maxParallelism
+	}
+}
+
+func WithScrubbers[T, F any](scrubbers ...Scrubber[T]) ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.Scrubbers = append(c.Scrubbers, scrubbers...)
+	}
+}
+
+func WithTextScrubbers[T, F any](scrubbers ...TextScrubber) ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.TextScrubbers = append(c.TextScrubbers, scrubbers...)
+	}
+}
+
 func WithDiffOpts[T, F any](opts ...cmp.Option) ConfigOption[T, F] {
 	return func(c *TestConfig[T, F]) {
 		c.DiffOpts = append(c.DiffOpts, opts...)
@@ -499,6 +924,13 @@ func WithLoader[T, F any](loader Loader[T]) ConfigOption[T, F] {
 	}
 }
 
+func WithCodec[T, F any](codec Codec) ConfigOption[T, F] {
+	return func(c *TestConfig[T, F]) {
+		c.Formatter = CodecFormatter[T](codec)
+		c.Loader = CodecLoader[T](codec)
+	}
+}
+
 // stepConfigBuilder provides a fluent interface for building step test configurations
 // with automatic type inference and no explicit type parameters on options.
 type stepConfigBuilder[T, F any] struct {
@@ -562,12 +994,91 @@ func (b *stepConfigBuilder[T, F]) WithTearDown(fn TearDownFunc[F]) *stepConfigBu
 	return b
 }
 
+// WithSetUpSuite sets the function that creates fixtures shared between test cases
+func (b *stepConfigBuilder[T, F]) WithSetUpSuite(fn SuiteSetUpFunc[F]) *stepConfigBuilder[T, F] {
+	b.config.SetUpSuite = fn
+	return b
+}
+
+// WithTearDownSuite sets the function that cleans up shared fixtures after all test cases
+func (b *stepConfigBuilder[T, F]) WithTearDownSuite(fn SuiteTearDownFunc[F]) *stepConfigBuilder[T, F] {
+	b.config.TearDownSuite = fn
+	return b
+}
+
+// WithReset sets the function that cleans a shared fixture between test cases
+func (b *stepConfigBuilder[T, F]) WithReset(fn ResetFunc[F]) *stepConfigBuilder[T, F] {
+	b.config.Reset = fn
+	return b
+}
+
+// WithBenchSetUp sets the fixture setup function of benchmarks
+func (b *stepConfigBuilder[T, F]) WithBenchSetUp(fn BenchSetUpFunc[F]) *stepConfigBuilder[T, F] {
+	b.config.BenchSetUp = fn
+	return b
+}
+
+// WithBenchTearDown sets the fixture teardown function of benchmarks
+func (b *stepConfigBuilder[T, F]) WithBenchTearDown(fn BenchTearDownFunc[F]) *stepConfigBuilder[T, F] {
+	b.config.BenchTearDown = fn
+	return b
+}
+
 // WithErrorHandling sets error handling configuration
 func (b *stepConfigBuilder[T, F]) WithErrorHandling(errorFunc ErrorFunc) *stepConfigBuilder[T, F] {
 	b.config.ErrorFunc = errorFunc
 	return b
 }
 
+// WithErrorGolden sets structured error handling configuration
+func (b *stepConfigBuilder[T, F]) WithErrorGolden(errorGolden ErrorGolden) *stepConfigBuilder[T, F] {
+	b.config.ErrorGolden = errorGolden
+	return b
+}
+
+// WithRecursive discovers test cases in nested groups of subdirectories
+func (b *stepConfigBuilder[T, F]) WithRecursive() *stepConfigBuilder[T, F] {
+	b.config.Recursive = true
+	return b
+}
+
+// WithParallel runs test cases in parallel, at most maxParallelism at a time (0 for no limit)
+func (b *stepConfigBuilder[T, F]) WithParallel(maxParallelism int) *stepConfigBuilder[T, F] {
+	b.config.Parallel = true
+	b.config.MaxParallelism = maxParallelism
+	return b
+}
+
+// WithStepTimeout sets the deadline of each step
+func (b *stepConfigBuilder[T, F]) WithStepTimeout(timeout time.Duration) *stepConfigBuilder[T, F] {
+	b.config.StepTimeout = timeout
+	return b
+}
+
+// WithCaseTimeout sets the deadline of each test case
+func (b *stepConfigBuilder[T, F]) WithCaseTimeout(timeout time.Duration) *stepConfigBuilder[T, F] {
+	b.config.CaseTimeout = timeout
+	return b
+}
+
+// WithCapture sets how values captured by steps are resolved
+func (b *stepConfigBuilder[T, F]) WithCapture(fn CaptureFunc[T]) *stepConfigBuilder[T, F] {
+	b.config.Capture = fn
+	return b
+}
+
+// WithScrubbers adds scrubbers for nondeterministic values in results
+func (b *stepConfigBuilder[T, F]) WithScrubbers(scrubbers ...Scrubber[T]) *stepConfigBuilder[T, F] {
+	b.config.Scrubbers = append(b.config.Scrubbers, scrubbers...)
+	return b
+}
+
+// WithTextScrubbers adds regexp scrubbers for formatted results and errors
+func (b *stepConfigBuilder[T, F]) WithTextScrubbers(scrubbers ...TextScrubber) *stepConfigBuilder[T, F] {
+	b.config.TextScrubbers = append(b.config.TextScrubbers, scrubbers...)
+	return b
+}
+
 // WithDiffOpts adds comparison options for cmp.Diff
 func (b *stepConfigBuilder[T, F]) WithDiffOpts(opts ...cmp.Option) *stepConfigBuilder[T, F] {
 	b.config.DiffOpts = append(b.config.DiffOpts, opts...)
@@ -586,6 +1097,13 @@ func (b *stepConfigBuilder[T, F]) WithLoader(loader Loader[T]) *stepConfigBuilde
 	return b
 }
 
+// WithCodec sets the formatter and loader to those of a codec
+func (b *stepConfigBuilder[T, F]) WithCodec(codec Codec) *stepConfigBuilder[T, F] {
+	b.config.Formatter = CodecFormatter[T](codec)
+	b.config.Loader = CodecLoader[T](codec)
+	return b
+}
+
 // Build returns the final TestConfig
 func (b *stepConfigBuilder[T, F]) Build() *TestConfig[T, F] {
 	return b.config
@@ -596,6 +1114,16 @@ func (b *stepConfigBuilder[T, F]) RunTests(t *testing.T, dir string) {
 	b.Build().RunTests(t, dir)
 }
 
+// RunTestsFS builds the configuration and runs the tests in dir of fsys
+func (b *stepConfigBuilder[T, F]) RunTestsFS(t *testing.T, fsys fs.FS, dir string) {
+	b.Build().RunTestsFS(t, fsys, dir)
+}
+
+// RunBenchmarks builds the configuration and benchmarks the test cases in dir
+func (b *stepConfigBuilder[T, F]) RunBenchmarks(tb *testing.B, dir string) {
+	b.Build().RunBenchmarks(tb, dir)
+}
+
 // oneShotConfigBuilder provides a fluent interface for building one-shot test configurations
 // with automatic type inference and no explicit type parameters on options.
 type oneShotConfigBuilder[T, F any] struct {
@@ -659,12 +1187,73 @@ func (b *oneShotConfigBuilder[T, F]) WithTearDown(fn TearDownFunc[F]) *oneShotCo
 	return b
 }
 
+// WithSetUpSuite sets the function that creates fixtures shared between test cases
+func (b *oneShotConfigBuilder[T, F]) WithSetUpSuite(fn SuiteSetUpFunc[F]) *oneShotConfigBuilder[T, F] {
+	b.config.SetUpSuite = fn
+	return b
+}
+
+// WithTearDownSuite sets the function that cleans up shared fixtures after all test cases
+func (b *oneShotConfigBuilder[T, F]) WithTearDownSuite(fn SuiteTearDownFunc[F]) *oneShotConfigBuilder[T, F] {
+	b.config.TearDownSuite = fn
+	return b
+}
+
+// WithReset sets the function that cleans a shared fixture between test cases
+func (b *oneShotConfigBuilder[T, F]) WithReset(fn ResetFunc[F]) *oneShotConfigBuilder[T, F] {
+	b.config.Reset = fn
+	return b
+}
+
+// WithBenchSetUp sets the fixture setup function of benchmarks
+func (b *oneShotConfigBuilder[T, F]) WithBenchSetUp(fn BenchSetUpFunc[F]) *oneShotConfigBuilder[T, F] {
+	b.config.BenchSetUp = fn
+	return b
+}
+
+// WithBenchTearDown sets the fixture teardown function of benchmarks
+func (b *oneShotConfigBuilder[T, F]) WithBenchTearDown(fn BenchTearDownFunc[F]) *oneShotConfigBuilder[T, F] {
+	b.config.BenchTearDown = fn
+	return b
+}
+
 // WithErrorHandling sets error handling configuration
 func (b *oneShotConfigBuilder[T, F]) WithErrorHandling(errorFunc ErrorFunc) *oneShotConfigBuilder[T, F] {
 	b.config.ErrorFunc = errorFunc
 	return b
 }
 
+// WithErrorGolden sets structured error handling configuration
+func (b *oneShotConfigBuilder[T, F]) WithErrorGolden(errorGolden ErrorGolden) *oneShotConfigBuilder[T, F] {
+	b.config.ErrorGolden = errorGolden
+	return b
+}
+
+// WithRecursive discovers test cases in nested groups of subdirectories
+func (b *oneShotConfigBuilder[T, F]) WithRecursive() *oneShotConfigBuilder[T, F] {
+	b.config.Recursive = true
+	return b
+}
+
+// WithParallel runs test cases in parallel, at most maxParallelism at a time (0 for no limit)
+func (b *oneShotConfigBuilder[T, F]) WithParallel(maxParallelism int) *oneShotConfigBuilder[T, F] {
+	b.config.Parallel = true
+	b.config.MaxParallelism = maxParallelism
+	return b
+}
+
+// WithScrubbers adds scrubbers for nondeterministic values in results
+func (b *oneShotConfigBuilder[T, F]) WithScrubbers(scrubbers ...Scrubber[T]) *oneShotConfigBuilder[T, F] {
+	b.config.Scrubbers = append(b.config.Scrubbers, scrubbers...)
+	return b
+}
+
+// WithTextScrubbers adds regexp scrubbers for formatted results and errors
+func (b *oneShotConfigBuilder[T, F]) WithTextScrubbers(scrubbers ...TextScrubber) *oneShotConfigBuilder[T, F] {
+	b.config.TextScrubbers = append(b.config.TextScrubbers, scrubbers...)
+	return b
+}
+
 // WithDiffOpts adds comparison options for cmp.Diff
 func (b *oneShotConfigBuilder[T, F]) WithDiffOpts(opts ...cmp.Option) *oneShotConfigBuilder[T, F] {
 	b.config.DiffOpts = append(b.config.DiffOpts, opts...)
@@ -683,6 +1272,13 @@ func (b *oneShotConfigBuilder[T, F]) WithLoader(loader Loader[T]) *oneShotConfig
 	return b
 }
 
+// WithCodec sets the formatter and loader to those of a codec
+func (b *oneShotConfigBuilder[T, F]) WithCodec(codec Codec) *oneShotConfigBuilder[T, F] {
+	b.config.Formatter = CodecFormatter[T](codec)
+	b.config.Loader = CodecLoader[T](codec)
+	return b
+}
+
 // Build returns the final TestConfig
 func (b *oneShotConfigBuilder[T, F]) Build() *TestConfig[T, F] {
 	return b.config
@@ -693,6 +1289,16 @@ func (b *oneShotConfigBuilder[T, F]) RunTests(t *testing.T, dir string) {
 	b.Build().RunTests(t, dir)
 }
 
+// RunTestsFS builds the configuration and runs the tests in dir of fsys
+func (b *oneShotConfigBuilder[T, F]) RunTestsFS(t *testing.T, fsys fs.FS, dir string) {
+	b.Build().RunTestsFS(t, fsys, dir)
+}
+
+// RunBenchmarks builds the configuration and benchmarks the test cases in dir
+func (b *oneShotConfigBuilder[T, F]) RunBenchmarks(tb *testing.B, dir string) {
+	b.Build().RunBenchmarks(tb, dir)
+}
+
 // RunTests runs golden file tests for all files in the specified directory.
 //
 // This is the main entry point for the golden test framework. It automatically
@@ -717,7 +1323,46 @@ func (b *oneShotConfigBuilder[T, F]) RunTests(t *testing.T, dir string) {
 // the error output against .out.txt files, and success cases by comparing the
 // result against output files using the configured Formatter and Loader.
 func (config *TestConfig[T, F]) RunTests(t *testing.T, dir string) {
-	// Check which test functions are set and dispatch accordingly
+	config.RunTestsFS(t, DirFS(dir), ".")
+}
+
+// RunTestsFS is like RunTests, but reads the test files from dir in fsys, such
+// as an embed.FS or an fstest.MapFS. -update can only change golden files if
+// fsys is a WritableFS, such as the one returned by DirFS; otherwise, every
+// golden file it would write fails its test case.
+//
+// Example:
+//
+//	//go:embed testdata
+//	var testdata embed.FS
+//
+//	config.RunTestsFS(t, testdata, "testdata")
+func (config *TestConfig[T, F]) RunTestsFS(t *testing.T, fsys fs.FS, dir string) {
+	config.validate(t)
+	if *GoldenReport != "" {
+		// Registered first so that it runs last, once every test case is done.
+		t.Cleanup(func() { writeReport(t) })
+	}
+
+	r := &runner[T, F]{config: config, suiteCtx: t.Context()}
+	if config.MaxParallelism > 0 {
+		r.sem = make(chan struct{}, config.MaxParallelism)
+	}
+	if config.SetUpSuite != nil {
+		// Cleanups run after all subtests, including parallel ones, have finished.
+		t.Cleanup(func() { r.tearDownSuite(t) })
+	}
+
+	if config.TestOneShotFunc != nil {
+		r.runOneShotTests(t, fsys, dir)
+	} else {
+		r.runStepTests(t, fsys, dir)
+	}
+}
+
+// validate fails tb if the config is invalid, and fills in the defaults of
+// the fields that are not set.
+func (config *TestConfig[T, F]) validate(t testing.TB) {
 	oneShotFuncSet := config.TestOneShotFunc != nil
 	stepTestFuncSet := config.StepTestFunc != nil
 
@@ -729,18 +1374,21 @@ func (config *TestConfig[T, F]) RunTests(t *testing.T, dir string) {
 	}
 
 	// Validate error handling configuration
-	errorFuncSet := config.ErrorFunc != nil
+	if config.ErrorFunc != nil && config.ErrorGolden != nil {
+		t.Fatal("TestConfig has both ErrorFunc and ErrorGolden set - use either flat or structured error outputs")
+	}
+	errorFuncSet := config.errorHandling()
 	errorPrefixSet := config.ErrorPrefix != ""
 	errorOutputExtSet := config.ErrorOutputExt != ""
 
-	// ErrorFunc and ErrorOutputExt must both be set or both unset
+	// ErrorFunc (or ErrorGolden) and ErrorOutputExt must both be set or both unset
 	if (errorFuncSet && !errorOutputExtSet) || (!errorFuncSet && errorOutputExtSet) {
-		t.Fatal("TestConfig error handling fields ErrorFunc and ErrorOutputExt must both be set or both unset")
+		t.Fatal("TestConfig error handling fields ErrorFunc (or ErrorGolden) and ErrorOutputExt must both be set or both unset")
 	}
 
-	// ErrorPrefix is only valid if ErrorFunc is set
+	// ErrorPrefix is only valid if ErrorFunc or ErrorGolden is set
 	if errorPrefixSet && !errorFuncSet {
-		t.Fatal("TestConfig ErrorPrefix is set but ErrorFunc is not - ErrorPrefix requires ErrorFunc to be set")
+		t.Fatal("TestConfig ErrorPrefix is set but ErrorFunc is not - ErrorPrefix requires ErrorFunc or ErrorGolden to be set")
 	}
 
 	// Set default ErrorPrefix if error handling is enabled but no prefix specified
@@ -748,64 +1396,163 @@ func (config *TestConfig[T, F]) RunTests(t *testing.T, dir string) {
 		config.ErrorPrefix = "error_"
 	}
 
-	// Set default formatter and loader if not provided
+	// Set default formatter and loader if not provided, from the codec for the
+	// output extension if there is one
+	codec, ok := LookupCodec(config.SuccessOutputExt)
+	if !ok {
+		codec = defaultCodec[T]()
+	}
 	if config.Formatter == nil {
-		config.Formatter = DefaultFormatter[T]()
+		config.Formatter = CodecFormatter[T](codec)
 	}
 	if config.Loader == nil {
-		config.Loader = DefaultLoader[T]()
+		config.Loader = CodecLoader[T](codec)
 	}
 
-	if oneShotFuncSet {
-		config.runOneShotTests(t, dir)
-	} else {
-		config.runStepTests(t, dir)
+	if config.StepTimeout < 0 || config.CaseTimeout < 0 {
+		t.Fatal("TestConfig StepTimeout and CaseTimeout must not be negative")
+	}
+	if (config.StepTimeout > 0 || config.CaseTimeout > 0) && !stepTestFuncSet {
+		t.Fatal("TestConfig StepTimeout or CaseTimeout is set but StepTestFunc is not - timeouts only apply to step tests")
+	}
+
+	if config.Capture != nil && !stepTestFuncSet {
+		t.Fatal("TestConfig Capture is set but StepTestFunc is not - captures only apply to step tests")
+	}
+
+	if config.MaxParallelism < 0 {
+		t.Fatal("TestConfig MaxParallelism must not be negative")
+	}
+	if config.MaxParallelism > 0 && !config.Parallel {
+		t.Fatal("TestConfig MaxParallelism is set but Parallel is not - MaxParallelism requires Parallel to be set")
+	}
+
+	suiteFixtureSet := config.SetUpSuite != nil
+	if suiteFixtureSet && (config.SetUp != nil || config.TearDown != nil) {
+		t.Fatal("TestConfig has both SetUpSuite and SetUp or TearDown set - use either per-case or suite fixtures")
+	}
+	if !suiteFixtureSet && (config.TearDownSuite != nil || config.Reset != nil) {
+		t.Fatal("TestConfig TearDownSuite or Reset is set but SetUpSuite is not - they require SetUpSuite to be set")
+	}
+	if config.BenchSetUp == nil && config.BenchTearDown != nil {
+		t.Fatal("TestConfig BenchTearDown is set but BenchSetUp is not - it requires BenchSetUp to be set")
 	}
 }
 
+// runner holds the state of a single RunTests call.
+type runner[T, F any] struct {
+	config *TestConfig[T, F]
+	// suiteCtx is the context of the RunTests call. Suite fixtures are created
+	// with it so that they outlive the test case that created them.
+	suiteCtx context.Context
+	// sem limits the number of test cases running at once. It is nil when
+	// there is no limit.
+	sem chan struct{}
+	// pool holds the fixtures created by SetUpSuite.
+	pool fixturePool[F]
+}
+
+// runCase runs fn as a subtest called name with the metadata returned by load
+// and a fixture from acquireFixture. The subtest is skipped if its metadata
+// says so, and parallel if the config asks for it. fn calls discard if the
+// fixture must not be reused because something may still be using it.
+func (r *runner[T, F]) runCase(t *testing.T, name string, load func() (*CaseMetadata, error), fn func(t *testing.T, meta *CaseMetadata, fixture F, discard func())) {
+	config := r.config
+	t.Run(name, func(t *testing.T) {
+		reportCase(t)
+		meta, err := load()
+		if err == nil {
+			err = config.checkFixtureParams(meta)
+		}
+		if err != nil {
+			t.Fatalf("failed to load test case %s: %v", name, err)
+		}
+		if reason := meta.skipReason(); reason != "" {
+			t.Skip(reason)
+		}
+		// SetUp reads the metadata with Metadata
+		setMetadata(t, meta)
+
+		if config.Parallel {
+			t.Parallel()
+			if r.sem != nil {
+				r.sem <- struct{}{}
+				defer func() { <-r.sem }()
+			}
+		}
+
+		fixture, release := r.acquireFixture(t, name)
+		reuse := true
+		// Ensure the fixture is released even if the test fails
+		defer func() { release(reuse) }()
+
+		fn(t, meta, fixture, func() { reuse = false })
+	})
+}
+
+// runGroup runs fn, which runs the test cases in a subdirectory, as a subtest
+// called name. The group is parallel if the config asks for it, so that its
+// test cases run alongside those of other groups; runCase still limits how
+// many run at once.
+func (r *runner[T, F]) runGroup(t *testing.T, name string, fn func(t *testing.T)) {
+	t.Run(name, func(t *testing.T) {
+		if r.config.Parallel {
+			t.Parallel()
+		}
+		fn(t)
+	})
+}
+
 // runOneShotTests runs golden file tests for all files in the specified directory
-func (config *TestConfig[T, F]) runOneShotTests(t *testing.T, dir string) {
-	files, err := os.ReadDir(dir)
+func (r *runner[T, F]) runOneShotTests(t *testing.T, fsys fs.FS, dir string) {
+	config := r.config
+	caseFiles := dirFiles{fsys: fsys, dir: dir}
+	files, err := fs.ReadDir(fsys, dir)
 	if err != nil {
 		t.Fatalf("failed to read testdata directory: %v", err)
 	}
 
+	// Every input has exactly one output, chosen by whether it is an error case
+	outputs := map[string][]string{}
 	for _, file := range files {
-		if filepath.Ext(file.Name()) != config.InputExt {
+		if !config.isOneShotInput(file) {
 			continue
 		}
+		name := strings.TrimSuffix(file.Name(), config.InputExt)
+		if config.errorHandling() && strings.HasPrefix(file.Name(), config.ErrorPrefix) {
+			outputs[name] = []string{name + ".out" + config.ErrorOutputExt}
+		} else {
+			outputs[name] = []string{name + ".out" + config.SuccessOutputExt}
+		}
+	}
+	config.pruneStaleOutputs(t, caseFiles, outputs)
 
-		t.Run(file.Name(), func(t *testing.T) {
-			// Set up fixture for this test case
-			var fixture F
-			var setUpErr error
-			if config.SetUp != nil {
-				fixture, setUpErr = config.SetUp(t)
-				if setUpErr != nil {
-					t.Fatalf("SetUp failed for file %s: %v", file.Name(), setUpErr)
-				}
-			}
-
-			// Ensure teardown runs even if test fails
-			defer func() {
-				if config.TearDown != nil {
-					if tearDownErr := config.TearDown(t, fixture); tearDownErr != nil {
-						t.Errorf("TearDown failed for file %s: %v", file.Name(), tearDownErr)
-					}
-				}
-			}()
+	for _, file := range files {
+		if !config.isOneShotInput(file) {
+			continue
+		}
 
-			filePath := filepath.Join(dir, file.Name())
-			data, err := os.ReadFile(filePath)
+		metaFile := strings.TrimSuffix(file.Name(), config.InputExt) + MetadataExt
+		load := func() (*CaseMetadata, error) {
+			meta, err := loadMetadata(caseFiles, metaFile)
+			if err == nil && meta.Timeout > 0 {
+				err = fmt.Errorf("%s sets a timeout, which only applies to step tests", caseFiles.path(metaFile))
+			}
+			return meta, err
+		}
+		r.runCase(t, file.Name(), load, func(t *testing.T, _ *CaseMetadata, fixture F, _ func()) {
+			filePath := caseFiles.path(file.Name())
+			data, err := caseFiles.read(file.Name())
 			if err != nil {
 				t.Fatalf("failed to read file %s: %v", file.Name(), err)
 			}
 
 			outputFile := strings.TrimSuffix(file.Name(), config.InputExt)
 			result, testErr := config.TestOneShotFunc(fixture, filePath, data)
+			placeholders := NewPlaceholders()
 
 			// Check if error handling is configured
-			errorHandlingEnabled := config.ErrorFunc != nil
+			errorHandlingEnabled := config.errorHandling()
 
 			if errorHandlingEnabled && strings.HasPrefix(file.Name(), config.ErrorPrefix) {
 				// This is an error test case
@@ -813,7 +1560,7 @@ func (config *TestConfig[T, F]) runOneShotTests(t *testing.T, dir string) {
 					t.Errorf("expected error for file %s, but got none", file.Name())
 					return
 				}
-				config.testErrorCase(t, dir, file.Name(), outputFile, testErr, config.ErrorFunc)
+				config.testErrorCase(t, caseFiles, file.Name(), outputFile, testErr, placeholders)
 			} else {
 				// This is a success test case (or error handling is disabled)
 				if testErr != nil {
@@ -825,48 +1572,55 @@ func (config *TestConfig[T, F]) runOneShotTests(t *testing.T, dir string) {
 					t.Errorf("unexpected error for file %s: %v", file.Name(), testErr)
 					return
 				}
-				config.testSuccessCase(t, dir, file.Name(), outputFile, result, testErr)
+				config.testSuccessCase(t, caseFiles, file.Name(), outputFile, result, testErr, placeholders)
 			}
 		})
 	}
+
+	if !config.Recursive {
+		return
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			group := path.Join(dir, file.Name())
+			r.runGroup(t, file.Name(), func(t *testing.T) { r.runOneShotTests(t, fsys, group) })
+		}
+	}
 }
 
-func (config *TestConfig[T, F]) testErrorCase(t *testing.T, dir, fileName, outputFile string, testErr error, errorFunc ErrorFunc) {
+// isOneShotInput reports whether file is the input of a one-shot test case.
+// Metadata files are not inputs, even if they share the input extension.
+func (config *TestConfig[T, F]) isOneShotInput(file fs.DirEntry) bool {
+	return !file.IsDir() && filepath.Ext(file.Name()) == config.InputExt && !strings.HasSuffix(file.Name(), MetadataExt)
+}
+
+func (config *TestConfig[T, F]) testErrorCase(t *testing.T, files caseFiles, fileName, outputFile string, testErr error, placeholders *Placeholders) {
 	outputFile += ".out" + config.ErrorOutputExt
 	if testErr == nil {
 		t.Errorf("expected error for file %s, but got none", fileName)
 		return
 	}
-
-	expectedError, readErr := os.ReadFile(filepath.Join(dir, outputFile))
-	if readErr != nil {
-		t.Logf("failed to read expected error output file: %v", readErr)
-	}
-
-	actualError := errorFunc(testErr)
-	if !bytes.Equal(expectedError, actualError) {
-		if *Update {
-			if writeErr := os.WriteFile(filepath.Join(dir, outputFile), actualError, 0644); writeErr != nil {
-				t.Errorf("failed to update error output file: %v", writeErr)
-			}
-			return
-		}
-		t.Errorf("error output mismatch for file %s:\nExpected:\n%s\nGot:\n%s", fileName, expectedError, actualError)
-	}
+	config.testErrorOutput(t, files, fileName, outputFile, testErr, placeholders)
 }
 
-func (config *TestConfig[T, F]) testSuccessCase(t *testing.T, dir, fileName, outputFile string, result T, testErr error) {
+func (config *TestConfig[T, F]) testSuccessCase(t *testing.T, files caseFiles, fileName, outputFile string, result T, testErr error, placeholders *Placeholders) {
 	outputFile += ".out" + config.SuccessOutputExt
 	if testErr != nil {
 		t.Errorf("unexpected error for file %s: %v", fileName, testErr)
 		return
 	}
 
+	result, scrubErr := config.scrub(result, placeholders)
+	if scrubErr != nil {
+		t.Errorf("%s: %v", fileName, scrubErr)
+		return
+	}
+
 	// Use the configured formatter and loader (defaults set in RunTests)
 
-	expectedData, readErr := os.ReadFile(filepath.Join(dir, outputFile))
-	if readErr != nil {
-		t.Logf("failed to read expected output file: %v", readErr)
+	expectedData, ok := readGolden(t, files, outputFile, func() ([]byte, error) { return config.Formatter(result) })
+	if !ok {
+		return
 	}
 
 	// Load expected value from golden file
@@ -883,7 +1637,9 @@ func (config *TestConfig[T, F]) testSuccessCase(t *testing.T, dir, fileName, out
 
 	// Compare the actual T objects
 	if diff := cmp.Diff(expected, result, diffOpts...); diff != "" {
-		if *Update {
+		report := config.mismatchReport(files.path(outputFile), expectedData, result, diff)
+		reportDiff(t, report)
+		if Update.rewrites(t.Name()) {
 			// Format the actual result for writing to golden file
 			actualData, formatErr := config.Formatter(result)
 			if formatErr != nil {
@@ -891,11 +1647,12 @@ func (config *TestConfig[T, F]) testSuccessCase(t *testing.T, dir, fileName, out
 				return
 			}
 
-			if writeErr := os.WriteFile(filepath.Join(dir, outputFile), actualData, 0644); writeErr != nil {
-				t.Errorf("failed to update output file %s: %v", outputFile, writeErr)
-			}
+			updateGolden(t, files, outputFile, actualData)
 			return
 		}
-		t.Errorf("output mismatch for file %s (-expected +got):\n%s", fileName, diff)
+		writePending(t, files, outputFile, func() ([]byte, error) { return config.Formatter(result) })
+		t.Errorf("output mismatch for file %s:\n%s", fileName, report)
+		return
 	}
+	config.checkFormatted(t, files, outputFile, expectedData, result)
 }
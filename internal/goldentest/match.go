@@ -0,0 +1,106 @@
+package goldentest
+
+import (
+	"flag"
+	"regexp"
+	"strings"
+)
+
+// testSelected reports whether -test.run selects the test named name, and
+// -test.skip does not skip it, the way the testing package matches them. name
+// is a full test name such as "TestGolden/case/step_2", with the rewriting of
+// t.Name() already applied.
+func testSelected(name string) bool {
+	elems := strings.Split(name, "/")
+	if run := testFlag("test.run"); run != "" {
+		if ok, _ := matchPattern(run, elems); !ok {
+			return false
+		}
+	}
+	if skip := testFlag("test.skip"); skip != "" {
+		if ok, partial := matchPattern(skip, elems); ok && !partial {
+			return false
+		}
+	}
+	return true
+}
+
+// testFlag returns the value of the testing flag name, or "" if it is not
+// registered.
+func testFlag(name string) string {
+	if f := flag.Lookup(name); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
+
+// matchPattern matches the elements of a test name against pattern, a list of
+// alternatives separated by '|', each a regexp for every level of the name
+// separated by '/'. partial reports whether the name only matched because it
+// has fewer levels than an alternative, so a test of its subtests may match.
+// Elements that are not valid regexps match, since testing rejects them
+// before any test runs.
+func matchPattern(pattern string, elems []string) (ok, partial bool) {
+	for _, alternative := range splitPattern(pattern) {
+		if altOK, altPartial := matchElems(alternative, elems); altOK {
+			ok = true
+			partial = partial || altPartial
+		}
+	}
+	return ok, partial
+}
+
+// matchElems matches the elements of a test name against the regexps of one
+// alternative of a pattern.
+func matchElems(regexps, elems []string) (ok, partial bool) {
+	for i, elem := range elems {
+		if i >= len(regexps) {
+			break
+		}
+		if matched, err := regexp.MatchString(regexps[i], elem); err == nil && !matched {
+			return false, false
+		}
+	}
+	return true, len(elems) < len(regexps)
+}
+
+// splitPattern splits pattern into its alternatives, and each alternative
+// into the regexps of its levels. Separators inside brackets or parentheses,
+// or escaped by a backslash, belong to the regexp.
+func splitPattern(pattern string) [][]string {
+	var alternatives [][]string
+	var levels []string
+	brackets, parens := 0, 0
+	start := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '[':
+			brackets++
+		case ']':
+			// An unmatched ']' is a literal.
+			brackets = max(brackets-1, 0)
+		case '(':
+			if brackets == 0 {
+				parens++
+			}
+		case ')':
+			if brackets == 0 {
+				parens--
+			}
+		case '\\':
+			i++
+		case '/', '|':
+			if brackets != 0 || parens != 0 {
+				break
+			}
+			levels = append(levels, pattern[start:i])
+			start = i + 1
+			if pattern[i] == '|' {
+				alternatives = append(alternatives, levels)
+				levels = nil
+			}
+		}
+	}
+	levels = append(levels, pattern[start:])
+	return append(alternatives, levels)
+}
@@ -0,0 +1,30 @@
+package goldentest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, name string
+		ok, partial   bool
+	}{
+		{"TestGolden", "TestGolden/case/step_1", true, false},
+		{"TestGolden/case/step_2", "TestGolden/case/step_2", true, false},
+		{"TestGolden/case/step_2", "TestGolden/case/step_1", false, false},
+		{"TestGolden/case/step_2", "TestGolden/case", true, true},
+		{"TestGolden/other", "TestGolden/case/step_1", false, false},
+		{"TestGolden/case/step_1|TestGolden/case/step_3", "TestGolden/case/step_3", true, false},
+		{"TestGolden/case/step_1|TestGolden/case/step_3", "TestGolden/case/step_2", false, false},
+		{"TestGolden/case/step_[13]", "TestGolden/case/step_3", true, false},
+		// Separators inside brackets and parentheses are part of the regexp
+		{"TestGolden/(a|b)/step_1", "TestGolden/b/step_1", true, false},
+		{"TestGolden/[/|]", "TestGolden/|", true, false},
+	} {
+		ok, partial := matchPattern(tc.pattern, strings.Split(tc.name, "/"))
+		if ok != tc.ok || partial != tc.partial {
+			t.Errorf("matchPattern(%q, %q) = %v, %v, want %v, %v", tc.pattern, tc.name, ok, partial, tc.ok, tc.partial)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package goldentest
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
+	hcl "github.com/hashicorp/hcl/v2"
+)
+
+// MetadataFile is the name of the metadata file of a step test case, in its
+// directory or as a section of its archive.
+const MetadataFile = "case.hcl"
+
+// MetadataExt is the extension of the metadata file of a one-shot test case,
+// which replaces the input extension: example.hcl has example.meta.hcl.
+const MetadataExt = ".meta.hcl"
+
+// GoldenTags is a flag that selects test cases by the tags in their metadata.
+// Its value is a comma separated list of tags: a test case runs if it has at
+// least one of them, and none of the tags prefixed with "!". For example,
+// -golden-tags=admin,!slow runs the admin test cases that are not slow.
+var GoldenTags = flag.String("golden-tags", "", "run only test cases with one of these comma separated tags, and none of the tags prefixed with '!'")
+
+// CaseMetadata is the optional metadata of a test case, read from MetadataFile
+// for step test cases and from the MetadataExt file next to the input of a
+// one-shot test case:
+//
+//	skip_short  = true
+//	skip_reason = "starts a real database"
+//	tags        = ["slow", "admin"]
+//	timeout     = "30s"
+//
+//	fixture {
+//	  user  = "admin"
+//	  seeds = 3
+//	}
+type CaseMetadata struct {
+	// Skip skips the test case.
+	Skip bool
+	// SkipShort skips the test case with -short.
+	SkipShort bool
+	// SkipReason explains why the test case is skipped.
+	SkipReason string
+	// Tags select the test case with -golden-tags.
+	Tags []string
+	// Timeout replaces CaseTimeout for a step test case.
+	Timeout time.Duration
+	// Fixture holds free-form parameters for SetUp and BenchSetUp, which
+	// read them with Metadata. It is nil if the metadata file has no fixture
+	// block.
+	Fixture map[string]cty.Value
+}
+
+// metadataSchema is the schema of metadata files.
+var metadataSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "skip"},
+		{Name: "skip_short"},
+		{Name: "skip_reason"},
+		{Name: "tags"},
+		{Name: "timeout"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "fixture"},
+	},
+}
+
+// parseMetadata parses the metadata file filename, whose content is src.
+func parseMetadata(filename string, src []byte) (*CaseMetadata, error) {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	content, diags := file.Body.Content(metadataSchema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	meta := &CaseMetadata{}
+	targets := map[string]any{
+		"skip":        &meta.Skip,
+		"skip_short":  &meta.SkipShort,
+		"skip_reason": &meta.SkipReason,
+		"tags":        &meta.Tags,
+	}
+	for name, target := range targets {
+		if attr, ok := content.Attributes[name]; ok {
+			diags = diags.Extend(gohcl.DecodeExpression(attr.Expr, nil, target))
+		}
+	}
+	if attr, ok := content.Attributes["timeout"]; ok {
+		var timeout string
+		decodeDiags := gohcl.DecodeExpression(attr.Expr, nil, &timeout)
+		diags = diags.Extend(decodeDiags)
+		if !decodeDiags.HasErrors() {
+			meta.Timeout, diags = parseTimeout(attr, timeout, diags)
+		}
+	}
+
+	for i, block := range content.Blocks {
+		if i > 0 {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate fixture block",
+				Detail:   "A test case has at most one fixture block.",
+				Subject:  block.DefRange.Ptr(),
+			})
+			continue
+		}
+		attrs, attrDiags := block.Body.JustAttributes()
+		diags = diags.Extend(attrDiags)
+		meta.Fixture = map[string]cty.Value{}
+		for name, attr := range attrs {
+			value, valueDiags := attr.Expr.Value(nil)
+			diags = diags.Extend(valueDiags)
+			meta.Fixture[name] = value
+		}
+	}
+
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return meta, nil
+}
+
+// parseTimeout parses the value of the timeout attribute attr, appending a
+// diagnostic to diags if it is not a positive duration.
+func parseTimeout(attr *hcl.Attribute, value string, diags hcl.Diagnostics) (time.Duration, hcl.Diagnostics) {
+	timeout, err := time.ParseDuration(value)
+	if err == nil && timeout <= 0 {
+		err = fmt.Errorf("must be positive")
+	}
+	if err != nil {
+		return 0, diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid timeout",
+			Detail:   fmt.Sprintf("The timeout %q is not a valid duration such as \"30s\": %v.", value, err),
+			Subject:  attr.Expr.Range().Ptr(),
+		})
+	}
+	return timeout, diags
+}
+
+// loadMetadata returns the metadata in the named file of files, or empty
+// metadata if there is no such file.
+func loadMetadata(files caseFiles, name string) (*CaseMetadata, error) {
+	if !files.has(name) {
+		return &CaseMetadata{}, nil
+	}
+	src, err := files.read(name)
+	if err != nil {
+		return nil, err
+	}
+	return parseMetadata(files.path(name), src)
+}
+
+// skipReason returns why the test case should be skipped, or "" if it should
+// run.
+func (meta *CaseMetadata) skipReason() string {
+	reason := meta.SkipReason
+	switch {
+	case meta.Skip:
+		if reason == "" {
+			reason = "skipped by its metadata"
+		}
+	case meta.SkipShort && testing.Short():
+		if reason == "" {
+			reason = "skipped in short mode by its metadata"
+		}
+	case !tagsSelected(*GoldenTags, meta.Tags):
+		reason = fmt.Sprintf("tags %q are not selected by -golden-tags=%s", meta.Tags, *GoldenTags)
+	default:
+		return ""
+	}
+	return reason
+}
+
+// tagsSelected reports whether a test case with tags is selected by filter,
+// the value of -golden-tags.
+func tagsSelected(filter string, tags []string) bool {
+	if filter == "" {
+		return true
+	}
+	included, hasIncludes := false, false
+	for _, term := range strings.Split(filter, ",") {
+		if tag, ok := strings.CutPrefix(term, "!"); ok {
+			if slices.Contains(tags, tag) {
+				return false
+			}
+			continue
+		}
+		hasIncludes = true
+		included = included || slices.Contains(tags, term)
+	}
+	return included || !hasIncludes
+}
+
+// caseMetadata holds the metadata of running test cases, for Metadata.
+var caseMetadata sync.Map // map[testing.TB]*CaseMetadata
+
+// Metadata returns the metadata of the test case t, such as the fixture
+// parameters of its metadata file. It is meant for SetUp and BenchSetUp, which
+// receive the testing.T or testing.B of the test case; the metadata is looked
+// up by t, so it is only found with that exact value. It returns empty
+// metadata if t is not a test case or has no metadata file.
+//
+// Example:
+//
+//	config.SetUp = func(t *testing.T) (*ServerFixture, error) {
+//		user := "anonymous"
+//		if v, ok := goldentest.Metadata(t).Fixture["user"]; ok {
+//			user = v.AsString()
+//		}
+//		return startServerAs(user)
+//	}
+func Metadata(t testing.TB) *CaseMetadata {
+	if meta, ok := caseMetadata.Load(t); ok {
+		return meta.(*CaseMetadata)
+	}
+	return &CaseMetadata{}
+}
+
+// checkFixtureParams returns an error if meta has fixture parameters but the
+// fixtures of config are suite fixtures, which are created before the test
+// cases that use them and shared between them, so they cannot honor them.
+func (config *TestConfig[T, F]) checkFixtureParams(meta *CaseMetadata) error {
+	if meta.Fixture != nil && config.SetUpSuite != nil {
+		return fmt.Errorf("fixture blocks are not supported with SetUpSuite, whose fixtures are shared by test cases; use SetUp instead")
+	}
+	return nil
+}
+
+// setMetadata makes meta the metadata of the test case t until it completes.
+func setMetadata(t testing.TB, meta *CaseMetadata) {
+	caseMetadata.Store(t, meta)
+	t.Cleanup(func() { caseMetadata.Delete(t) })
+}
@@ -0,0 +1,184 @@
+package goldentest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestParseMetadata(t *testing.T) {
+	meta, err := parseMetadata("case.hcl", []byte(`
+skip_short  = true
+skip_reason = "slow"
+tags        = ["admin", "slow"]
+timeout     = "1m30s"
+
+fixture {
+  user  = "admin"
+  seeds = 3
+}
+`))
+	if err != nil {
+		t.Fatalf("parseMetadata: %v", err)
+	}
+	want := &CaseMetadata{
+		SkipShort:  true,
+		SkipReason: "slow",
+		Tags:       []string{"admin", "slow"},
+		Timeout:    90 * time.Second,
+		Fixture: map[string]cty.Value{
+			"user":  cty.StringVal("admin"),
+			"seeds": cty.NumberIntVal(3),
+		},
+	}
+	if diff := cmp.Diff(want, meta, cmp.Comparer(cty.Value.RawEquals)); diff != "" {
+		t.Errorf("metadata mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		name, src, want string
+	}{
+		{"unknown attribute", `retries = 3`, `Unsupported argument`},
+		{"wrong type", `skip = "yes"`, `Unsuitable value type`},
+		{"invalid timeout", `timeout = "soon"`, `Invalid timeout`},
+		{"negative timeout", `timeout = "-1s"`, `must be positive`},
+		{"duplicate fixture", "fixture {}\nfixture {}", `Duplicate fixture block`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseMetadata("case.hcl", []byte(tc.src))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("parseMetadata(%q) = %v, want an error containing %q", tc.src, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestTagsSelected(t *testing.T) {
+	for _, tc := range []struct {
+		filter string
+		tags   []string
+		want   bool
+	}{
+		{"", nil, true},
+		{"admin", []string{"admin", "slow"}, true},
+		{"admin", nil, false},
+		{"admin,billing", []string{"billing"}, true},
+		{"!slow", nil, true},
+		{"!slow", []string{"admin", "slow"}, false},
+		{"admin,!slow", []string{"admin"}, true},
+		{"admin,!slow", []string{"admin", "slow"}, false},
+	} {
+		if got := tagsSelected(tc.filter, tc.tags); got != tc.want {
+			t.Errorf("tagsSelected(%q, %q) = %v, want %v", tc.filter, tc.tags, got, tc.want)
+		}
+	}
+}
+
+func TestCheckFixtureParams(t *testing.T) {
+	params := &CaseMetadata{Fixture: map[string]cty.Value{}}
+	perCase := &TestConfig[string, string]{SetUp: func(t *testing.T) (string, error) { return "", nil }}
+	suite := &TestConfig[string, string]{SetUpSuite: func(ctx context.Context) (string, error) { return "", nil }}
+
+	if err := perCase.checkFixtureParams(params); err != nil {
+		t.Errorf("checkFixtureParams with SetUp: %v", err)
+	}
+	if err := suite.checkFixtureParams(&CaseMetadata{}); err != nil {
+		t.Errorf("checkFixtureParams without a fixture block: %v", err)
+	}
+	if err := suite.checkFixtureParams(params); err == nil {
+		t.Error("checkFixtureParams with SetUpSuite and a fixture block succeeded, want an error")
+	}
+}
+
+func TestRunStepTestsMetadata(t *testing.T) {
+	fsys := contents(map[string]string{
+		"skipped/case.hcl":   `skip = true`,
+		"skipped/1.in.hcl":   "broken",
+		"tagged/case.hcl":    `tags = ["slow"]`,
+		"tagged/1.in.hcl":    "broken",
+		"admin/case.hcl":     "fixture {\n  user = \"admin\"\n}",
+		"admin/1.in.hcl":     "whoami",
+		"admin/1.out.json":   "admin",
+		"default/1.in.hcl":   "whoami",
+		"default/1.out.json": "anonymous",
+		"timeout.txtar":      "-- case.hcl --\ntimeout = \"30s\"\n-- 1.in.hcl --\ndeadline\n-- 1.out.json --\nwithin 30s\n",
+	})
+
+	defer func(tags string) { *GoldenTags = tags }(*GoldenTags)
+	*GoldenTags = "!slow"
+
+	var ran []string
+	config := &TestConfig[string, string]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		CaseTimeout:      time.Hour,
+		SetUp: func(t *testing.T) (string, error) {
+			ran = append(ran, t.Name())
+			if user, ok := Metadata(t).Fixture["user"]; ok {
+				return user.AsString(), nil
+			}
+			return "anonymous", nil
+		},
+		StepTestFunc: func(ctx context.Context, user string, stepFile StepFile) (string, error) {
+			switch string(stepFile.Data) {
+			case "whoami":
+				return user, nil
+			case "deadline":
+				if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= 30*time.Second {
+					return "within 30s", nil
+				}
+				return "later", nil
+			}
+			return "", context.Canceled
+		},
+	}
+
+	config.RunTestsFS(t, fsys, ".")
+
+	want := []string{
+		"TestRunStepTestsMetadata/admin",
+		"TestRunStepTestsMetadata/default",
+		"TestRunStepTestsMetadata/timeout",
+	}
+	if diff := cmp.Diff(want, ran); diff != "" {
+		t.Errorf("test cases run mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunOneShotTestsMetadata(t *testing.T) {
+	fsys := contents(map[string]string{
+		"greeting.hcl":      "hello",
+		"greeting.out.json": "hello",
+		"skipped.hcl":       "broken",
+		"skipped.meta.hcl":  `skip = true`,
+		"fixture.hcl":       "whoami",
+		"fixture.meta.hcl":  "fixture {\n  user = \"admin\"\n}",
+		"fixture.out.json":  "admin",
+	})
+
+	config := &TestConfig[string, string]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		SetUp: func(t *testing.T) (string, error) {
+			if user, ok := Metadata(t).Fixture["user"]; ok {
+				return user.AsString(), nil
+			}
+			return "anonymous", nil
+		},
+		TestOneShotFunc: func(user, _ string, data []byte) (string, error) {
+			switch string(data) {
+			case "whoami":
+				return user, nil
+			case "broken":
+				return "", context.Canceled
+			}
+			return string(data), nil
+		},
+	}
+
+	config.RunTestsFS(t, fsys, ".")
+}
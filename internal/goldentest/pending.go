@@ -0,0 +1,108 @@
+package goldentest
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Pending is a pending version of a golden file, written by -update=pending,
+// which is reviewed by accepting it in place of the golden file or rejecting
+// it.
+type Pending struct {
+	// Golden is the path of the golden file: its path on disk, or the path of
+	// its txtar archive joined with its section name.
+	Golden string
+	// Missing is set if the golden file does not exist yet.
+	Missing bool
+	// Old is the content of the golden file, and New is its pending content.
+	Old, New []byte
+
+	files caseFiles
+	name  string
+}
+
+// FindPending returns the pending golden files in the testdata tree at dir,
+// in lexical order. Directories whose names start with "." or "_" are
+// skipped, like go test ./... skips them.
+func FindPending(dir string) ([]*Pending, error) {
+	return FindPendingFS(DirFS(dir), ".")
+}
+
+// FindPendingFS is like FindPending, but reads the testdata tree at dir in
+// fsys, which must be a WritableFS to accept or reject them.
+func FindPendingFS(fsys fs.FS, dir string) ([]*Pending, error) {
+	var pending []*Pending
+	add := func(files caseFiles, name string) error {
+		p := &Pending{Golden: files.path(name), files: files, name: name}
+		var err error
+		if p.New, err = files.readPending(name); err != nil {
+			return err
+		}
+		p.Old, err = files.read(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			p.Missing, err = true, nil
+		}
+		pending = append(pending, p)
+		return err
+	}
+
+	err := fs.WalkDir(fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			// Like go test ./..., skip directories such as .git
+			if name != dir && (strings.HasPrefix(entry.Name(), ".") || strings.HasPrefix(entry.Name(), "_")) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		golden, ok := strings.CutSuffix(name, PendingExt)
+		if !ok {
+			return nil
+		}
+		if strings.HasSuffix(golden, ArchiveExt) {
+			archive, err := openArchive(fsys, golden)
+			if err != nil {
+				return err
+			}
+			sections, err := archive.pendingArchive()
+			if err != nil {
+				return err
+			}
+			for _, section := range sections.archive.Files {
+				if err := add(archive, section.Name); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		// Only outputs have pending versions; other .new files are left alone
+		if !strings.Contains(path.Base(golden), ".out.") {
+			return nil
+		}
+		return add(dirFiles{fsys: fsys, dir: path.Dir(name)}, path.Base(golden))
+	})
+	return pending, err
+}
+
+// Diff returns a unified diff from the golden file to its pending version,
+// colored if color is set.
+func (p *Pending) Diff(color bool) string {
+	return unifiedDiff(p.Golden, p.Old, p.New, color)
+}
+
+// Accept replaces the golden file with its pending version.
+func (p *Pending) Accept() error {
+	if err := p.files.write(p.name, p.New); err != nil {
+		return err
+	}
+	return p.files.removePending(p.name)
+}
+
+// Reject deletes the pending version, leaving the golden file as it is.
+func (p *Pending) Reject() error {
+	return p.files.removePending(p.name)
+}
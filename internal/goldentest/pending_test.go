@@ -0,0 +1,84 @@
+package goldentest
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestPendingReview(t *testing.T) {
+	fsys := contents(map[string]string{
+		"greet/1.in.hcl":  "hello",
+		"greet/1.out.txt": "hi",
+		"retry.txtar":     "-- 1.in.hcl --\nretry\n-- 1.out.txt --\nfailed\n",
+	})
+	greet := dirFiles{fsys: fsys, dir: "greet"}
+	retry, err := openArchive(fsys, "retry.txtar")
+	if err != nil {
+		t.Fatalf("openArchive: %v", err)
+	}
+
+	defer func(update UpdateFlag) { *Update = update }(*Update)
+	*Update = UpdateFlag{Mode: UpdatePending}
+	writePending(t, greet, "1.out.txt", func() ([]byte, error) { return []byte("hello"), nil })
+	writePending(t, retry, "1.out.txt", func() ([]byte, error) { return []byte("retried"), nil })
+	writePending(t, retry, "2.out.txt", func() ([]byte, error) { return []byte("done"), nil })
+
+	// Pending files in directories go test ./... skips are not found
+	fsys[".git/1.out.txt"+PendingExt] = &fstest.MapFile{Data: []byte("object")}
+	fsys["_build/1.out.txt"+PendingExt] = &fstest.MapFile{Data: []byte("artifact")}
+
+	pending, err := FindPendingFS(fsys, ".")
+	if err != nil {
+		t.Fatalf("FindPendingFS: %v", err)
+	}
+	want := []*Pending{
+		{Golden: "greet/1.out.txt", Old: []byte("hi"), New: []byte("hello")},
+		{Golden: "retry.txtar/1.out.txt", Old: []byte("failed"), New: []byte("retried")},
+		{Golden: "retry.txtar/2.out.txt", Missing: true, New: []byte("done")},
+	}
+	if diff := cmp.Diff(want, pending, cmpopts.IgnoreUnexported(Pending{})); diff != "" {
+		t.Fatalf("pending mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, review := range []func() error{pending[0].Accept, pending[1].Reject, pending[2].Accept} {
+		if err := review(); err != nil {
+			t.Fatalf("review: %v", err)
+		}
+	}
+	got := map[string]string{}
+	for name, file := range fsys {
+		got[name] = string(file.Data)
+	}
+	wantFiles := map[string]string{
+		".git/1.out.txt" + PendingExt:   "object",
+		"_build/1.out.txt" + PendingExt: "artifact",
+		"greet/1.in.hcl":                "hello",
+		"greet/1.out.txt":               "hello",
+		"retry.txtar":                   "-- 1.in.hcl --\nretry\n-- 1.out.txt --\nfailed\n-- 2.out.txt --\ndone\n",
+	}
+	if diff := cmp.Diff(wantFiles, got); diff != "" {
+		t.Errorf("files after review mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPendingRemovedOnceMatching(t *testing.T) {
+	fsys := contents(map[string]string{
+		"greet/1.in.hcl":      "hello",
+		"greet/1.out.txt":     "hello",
+		"greet/1.out.txt.new": "outdated",
+	})
+
+	defer func(update UpdateFlag) { *Update = update }(*Update)
+	*Update = UpdateFlag{Mode: UpdatePending}
+	NewStepConfig(func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+		return string(stepFile.Data), nil
+	}).WithInputExt(".hcl").WithSuccessExt(".txt").RunTestsFS(t, fsys, ".")
+
+	if _, ok := fsys["greet/1.out.txt.new"]; ok {
+		t.Error("the pending version of greet/1.out.txt was kept, want it removed since the golden file matches")
+	}
+}
@@ -0,0 +1,311 @@
+package goldentest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// reportEnv is the environment variable that sets the default of
+// -golden-report.
+const reportEnv = "GOLDEN_REPORT"
+
+// GoldenReport is a flag naming a file to write a report of every test case
+// and step to, for CI to show which golden files changed and which steps
+// failed without parsing the test output. The report is JUnit XML if the name
+// ends in .xml, and JSON otherwise. It defaults to $GOLDEN_REPORT. A relative
+// name is resolved against the directory of the package under test, so that
+// go test ./... writes a report for each package. The test binaries of
+// go test ./... run at the same time, so an absolute name gets the package
+// added to it by reportPath rather than every package writing the same file.
+var GoldenReport = flag.String("golden-report", os.Getenv(reportEnv), "write a report of every golden test case and step to this file: JUnit XML if it ends in .xml, JSON otherwise")
+
+// Status values of a reportEntry.
+const (
+	statusPass = "pass"
+	statusFail = "fail"
+	statusSkip = "skip"
+)
+
+// reportEntry is the outcome of a test case, or of a step of a step test case.
+type reportEntry struct {
+	// Name is the full name of the test, such as "TestGreet/basic/step_1".
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Golden is the path of the golden file the result was compared against.
+	Golden string `json:"golden,omitempty"`
+	// Diff is the difference between the golden file and the result, without
+	// color.
+	Diff string `json:"diff,omitempty"`
+	// Updated is set if -update wrote or removed a golden file.
+	Updated bool `json:"updated,omitempty"`
+	// Duration is in seconds. The duration of a step only counts its call to
+	// StepTestFunc.
+	Duration float64        `json:"duration"`
+	Steps    []*reportEntry `json:"steps,omitempty"`
+}
+
+// report collects the entries of the test cases of every RunTests call in
+// the test binary.
+type report struct {
+	mu    sync.Mutex
+	cases []*reportEntry
+	// byTest indexes the entries of running test cases and steps by their
+	// test. Every run of a test, such as with -count, has its own entry.
+	byTest map[testing.TB]*reportEntry
+}
+
+// reports is the report written to -golden-report.
+var reports = &report{}
+
+// reportCase adds an entry for the test case t to the report, if
+// -golden-report is set, and records its status and duration once t is done.
+func reportCase(t *testing.T) {
+	reports.add(t, nil)
+}
+
+// reportStep is like reportCase for the step t of the test case c, whose
+// entry the step's is nested in.
+func reportStep(c, t *testing.T) {
+	reports.add(t, c)
+}
+
+// add adds an entry for t, nested in that of parent unless parent is nil.
+func (r *report) add(t, parent *testing.T) {
+	if *GoldenReport == "" {
+		return
+	}
+	entry := &reportEntry{Name: t.Name()}
+	r.mu.Lock()
+	if r.byTest == nil {
+		r.byTest = map[testing.TB]*reportEntry{}
+	}
+	if parentEntry, ok := r.byTest[parent]; ok {
+		parentEntry.Steps = append(parentEntry.Steps, entry)
+	} else {
+		r.cases = append(r.cases, entry)
+	}
+	r.byTest[t] = entry
+	r.mu.Unlock()
+
+	start := time.Now()
+	// Cleanups run after the subtests of t, so its status includes theirs.
+	t.Cleanup(func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		switch {
+		case t.Failed():
+			entry.Status = statusFail
+		case t.Skipped():
+			entry.Status = statusSkip
+		default:
+			entry.Status = statusPass
+		}
+		if entry.Duration == 0 {
+			entry.Duration = time.Since(start).Seconds()
+		}
+		delete(r.byTest, t)
+	})
+}
+
+// update calls fn with the report entry of t, if it has one.
+func (r *report) update(t testing.TB, fn func(entry *reportEntry)) {
+	if *GoldenReport == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.byTest[t]; ok {
+		fn(entry)
+	}
+}
+
+// reportDuration records how long the step t took.
+func reportDuration(t *testing.T, d time.Duration) {
+	reports.update(t, func(entry *reportEntry) { entry.Duration = d.Seconds() })
+}
+
+// reportGolden records that t compares against the golden file at path.
+func reportGolden(t testing.TB, path string) {
+	reports.update(t, func(entry *reportEntry) { entry.Golden = path })
+}
+
+// reportDiff records that the result of t differs from its golden file.
+func reportDiff(t testing.TB, diff string) {
+	reports.update(t, func(entry *reportEntry) { entry.Diff = ansiEscapes.ReplaceAllString(diff, "") })
+}
+
+// reportUpdated records that -update wrote or removed a golden file of t.
+func reportUpdated(t testing.TB) {
+	reports.update(t, func(entry *reportEntry) { entry.Updated = true })
+}
+
+// ansiEscapes matches the escape sequences that color diffs.
+var ansiEscapes = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// writeReport writes the report of every test case so far to -golden-report,
+// replacing the report of earlier RunTests calls.
+func writeReport(t *testing.T) {
+	name := reportPath(*GoldenReport)
+	reports.mu.Lock()
+	var data []byte
+	var err error
+	if filepath.Ext(name) == ".xml" {
+		data, err = reports.junit()
+	} else {
+		data, err = json.MarshalIndent(struct {
+			Cases []*reportEntry `json:"cases"`
+		}{reports.cases}, "", "  ")
+	}
+	reports.mu.Unlock()
+	if err != nil {
+		t.Errorf("failed to encode golden test report: %v", err)
+		return
+	}
+	if err := writeGolden(name, append(data, '\n')); err != nil {
+		t.Errorf("failed to write golden test report: %v", err)
+	}
+}
+
+// reportPath returns the file to write the report named name to. A relative
+// name is used as it is, in the directory of the package under test. An
+// absolute name gets the directory of the package, relative to its module,
+// added before its extension, e.g. /tmp/golden.internal_config.xml for the
+// package in internal/config, so that the packages of go test ./... each write
+// their own report.
+func reportPath(name string) string {
+	if !filepath.IsAbs(name) {
+		return name
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + packageID(dir) + ext
+}
+
+// packageID returns a file name part naming the package in dir: its path
+// relative to the root of its module, with separators replaced by
+// underscores, or the name of dir for the root package or outside a module.
+func packageID(dir string) string {
+	for root := dir; ; root = filepath.Dir(root) {
+		if _, err := os.Stat(filepath.Join(root, "go.mod")); err == nil {
+			if rel, err := filepath.Rel(root, dir); err == nil && rel != "." {
+				return strings.ReplaceAll(filepath.ToSlash(rel), "/", "_")
+			}
+			break
+		}
+		if filepath.Dir(root) == root {
+			break
+		}
+	}
+	return filepath.Base(dir)
+}
+
+// JUnit XML elements, as understood by most CI systems.
+type (
+	junitSuites struct {
+		XMLName xml.Name     `xml:"testsuites"`
+		Suites  []junitSuite `xml:"testsuite"`
+	}
+	junitSuite struct {
+		Name     string      `xml:"name,attr"`
+		Tests    int         `xml:"tests,attr"`
+		Failures int         `xml:"failures,attr"`
+		Skipped  int         `xml:"skipped,attr"`
+		Time     string      `xml:"time,attr"`
+		Cases    []junitCase `xml:"testcase"`
+	}
+	junitCase struct {
+		Name      string        `xml:"name,attr"`
+		Classname string        `xml:"classname,attr"`
+		Time      string        `xml:"time,attr"`
+		Failure   *junitMessage `xml:"failure,omitempty"`
+		Skipped   *junitMessage `xml:"skipped,omitempty"`
+		SystemOut string        `xml:"system-out,omitempty"`
+	}
+	junitMessage struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+// junit returns the report as JUnit XML, with a test suite for each top-level
+// test and a test case for each test case and step.
+func (r *report) junit() ([]byte, error) {
+	var suites junitSuites
+	suiteIndex := map[string]int{}
+	var add func(suite *junitSuite, entry *reportEntry)
+	add = func(suite *junitSuite, entry *reportEntry) {
+		suite.Cases = append(suite.Cases, junitEntry(entry))
+		suite.Tests++
+		switch entry.Status {
+		case statusFail:
+			suite.Failures++
+		case statusSkip:
+			suite.Skipped++
+		}
+		for _, step := range entry.Steps {
+			add(suite, step)
+		}
+	}
+	times := map[string]float64{}
+	for _, entry := range r.cases {
+		name, _, _ := strings.Cut(entry.Name, "/")
+		i, ok := suiteIndex[name]
+		if !ok {
+			i = len(suites.Suites)
+			suiteIndex[name] = i
+			suites.Suites = append(suites.Suites, junitSuite{Name: name})
+		}
+		add(&suites.Suites[i], entry)
+		times[name] += entry.Duration
+	}
+	for i, suite := range suites.Suites {
+		suites.Suites[i].Time = fmt.Sprintf("%.3f", times[suite.Name])
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// junitEntry returns the JUnit test case for entry.
+func junitEntry(entry *reportEntry) junitCase {
+	c := junitCase{
+		Name:      path.Base(entry.Name),
+		Classname: path.Dir(entry.Name),
+		Time:      fmt.Sprintf("%.3f", entry.Duration),
+	}
+	switch entry.Status {
+	case statusFail:
+		message := "test failed; see the test output"
+		if entry.Diff != "" {
+			message = fmt.Sprintf("result does not match golden file %s", entry.Golden)
+		}
+		c.Failure = &junitMessage{Message: message, Text: entry.Diff}
+	case statusSkip:
+		c.Skipped = &junitMessage{Message: "skipped"}
+	}
+	var out []string
+	if entry.Golden != "" {
+		out = append(out, "golden file: "+entry.Golden)
+	}
+	if entry.Updated {
+		out = append(out, "golden files updated by -update")
+	}
+	c.SystemOut = strings.Join(out, "\n")
+	return c
+}
@@ -0,0 +1,225 @@
+package goldentest
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// withReport sets -golden-report to a file named name in a temporary
+// directory, with an empty report, and returns the path it is written to.
+func withReport(t *testing.T, name string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	old := *GoldenReport
+	*GoldenReport = file
+	reports = &report{}
+	t.Cleanup(func() {
+		*GoldenReport = old
+		reports = &report{}
+	})
+	return reportPath(file)
+}
+
+// findReportEntry returns the entry of the test named name in entries or
+// their steps, or nil if there is none.
+func findReportEntry(entries []*reportEntry, name string) *reportEntry {
+	for _, entry := range entries {
+		if entry.Name == name {
+			return entry
+		}
+		if step := findReportEntry(entry.Steps, name); step != nil {
+			return step
+		}
+	}
+	return nil
+}
+
+func TestReportPath(t *testing.T) {
+	if got := reportPath("golden.xml"); got != "golden.xml" {
+		t.Errorf("reportPath(%q) = %q, want it unchanged", "golden.xml", got)
+	}
+	// Tests run in the directory of their package, internal/goldentest
+	abs := filepath.Join(t.TempDir(), "golden.xml")
+	want := filepath.Join(filepath.Dir(abs), "golden.internal_goldentest.xml")
+	if got := reportPath(abs); got != want {
+		t.Errorf("reportPath(%q) = %q, want %q", abs, got, want)
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	file := withReport(t, "report.json")
+	defer func(update UpdateFlag) { *Update = update }(*Update)
+	*Update = UpdateFlag{Mode: UpdateAll}
+
+	fsys := contents(map[string]string{
+		"greet/1.in.hcl":   "hello",
+		"greet/1.out.txt":  "hello",
+		"greet/2.in.hcl":   "bye",
+		"greet/2.out.txt":  "hi",
+		"skipped/case.hcl": "skip = true\nskip_reason = \"flaky\"",
+		"skipped/1.in.hcl": "hello",
+	})
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".txt",
+		StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+			return string(stepFile.Data), nil
+		},
+	}
+	// The report is written once the cases of the RunTests call are done
+	t.Run("suite", func(t *testing.T) {
+		config.RunTestsFS(t, fsys, ".")
+	})
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	var got struct {
+		Cases []*reportEntry `json:"cases"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to parse report: %v\n%s", err, data)
+	}
+	var clearDurations func(entries []*reportEntry)
+	clearDurations = func(entries []*reportEntry) {
+		for _, entry := range entries {
+			entry.Duration = 0
+			clearDurations(entry.Steps)
+		}
+	}
+	clearDurations(got.Cases)
+
+	want := []*reportEntry{
+		{
+			Name:   "TestReportJSON/suite/greet",
+			Status: statusPass,
+			Steps: []*reportEntry{
+				{Name: "TestReportJSON/suite/greet/step_1", Status: statusPass, Golden: "greet/1.out.txt"},
+				{
+					Name:    "TestReportJSON/suite/greet/step_2",
+					Status:  statusPass,
+					Golden:  "greet/2.out.txt",
+					Diff:    "--- greet/2.out.txt (golden)\n+++ greet/2.out.txt (got)\n@@ -1,1 +1,1 @@\n-hi\n+bye\n",
+					Updated: true,
+				},
+			},
+		},
+		{Name: "TestReportJSON/suite/skipped", Status: statusSkip},
+	}
+	if diff := cmp.Diff(want, got.Cases); diff != "" {
+		t.Errorf("report mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReportJUnit(t *testing.T) {
+	r := &report{cases: []*reportEntry{
+		{
+			Name:     "TestGreet/greet",
+			Status:   statusFail,
+			Duration: 0.25,
+			Steps: []*reportEntry{
+				{Name: "TestGreet/greet/step_1", Status: statusPass, Golden: "greet/1.out.txt", Duration: 0.1},
+				{Name: "TestGreet/greet/step_2", Status: statusFail, Golden: "greet/2.out.txt", Diff: "-hi\n+bye\n", Duration: 0.05},
+			},
+		},
+		{Name: "TestGreet/skipped", Status: statusSkip},
+		{Name: "TestParse/simple.hcl", Status: statusPass, Golden: "simple.out.json", Updated: true, Duration: 0.001},
+	}}
+
+	got, err := r.junit()
+	if err != nil {
+		t.Fatalf("junit: %v", err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="TestGreet" tests="4" failures="2" skipped="1" time="0.250">
+    <testcase name="greet" classname="TestGreet" time="0.250">
+      <failure message="test failed; see the test output"></failure>
+    </testcase>
+    <testcase name="step_1" classname="TestGreet/greet" time="0.100">
+      <system-out>golden file: greet/1.out.txt</system-out>
+    </testcase>
+    <testcase name="step_2" classname="TestGreet/greet" time="0.050">
+      <failure message="result does not match golden file greet/2.out.txt">-hi&#xA;+bye&#xA;</failure>
+      <system-out>golden file: greet/2.out.txt</system-out>
+    </testcase>
+    <testcase name="skipped" classname="TestGreet" time="0.000">
+      <skipped message="skipped"></skipped>
+    </testcase>
+  </testsuite>
+  <testsuite name="TestParse" tests="1" failures="0" skipped="0" time="0.001">
+    <testcase name="simple.hcl" classname="TestParse" time="0.001">
+      <system-out>golden file: simple.out.json&#xA;golden files updated by -update</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("JUnit report mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// repeatedRunsDirEnv names the testdata directory of the test cases run by
+// TestReportRepeatedRuns in a subprocess, which runs them with -count.
+const repeatedRunsDirEnv = "GOLDENTEST_REPEATED_RUNS_DIR"
+
+func TestReportRepeatedRuns(t *testing.T) {
+	if dir := os.Getenv(repeatedRunsDirEnv); dir != "" {
+		config := &TestConfig[string, struct{}]{
+			InputExt:         ".hcl",
+			SuccessOutputExt: ".txt",
+			StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+				return string(stepFile.Data), nil
+			},
+		}
+		config.RunTests(t, dir)
+		return
+	}
+
+	tempDir := t.TempDir()
+	stepDir := filepath.Join(tempDir, "greet")
+	if err := os.MkdirAll(stepDir, 0755); err != nil {
+		t.Fatalf("failed to create step dir: %v", err)
+	}
+	for filename, content := range map[string]string{
+		"1.in.hcl":  "hello",
+		"1.out.txt": "hello",
+	} {
+		if err := os.WriteFile(filepath.Join(stepDir, filename), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file %s: %v", filename, err)
+		}
+	}
+
+	file := filepath.Join(tempDir, "report.json")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestReportRepeatedRuns$", "-test.count=2", "-golden-report="+file)
+	cmd.Env = append(os.Environ(), repeatedRunsDirEnv+"="+tempDir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("test cases failed: %v\n%s", err, out)
+	}
+
+	data, err := os.ReadFile(reportPath(file))
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	var got struct {
+		Cases []*reportEntry `json:"cases"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to parse report: %v\n%s", err, data)
+	}
+	// Each run of the test case has its own entry, with its own step
+	if len(got.Cases) != 2 {
+		t.Fatalf("got %d case entries, want one for each of 2 runs:\n%s", len(got.Cases), data)
+	}
+	for i, entry := range got.Cases {
+		if entry.Name != "TestReportRepeatedRuns/greet" || entry.Status != statusPass || len(entry.Steps) != 1 || entry.Steps[0].Golden != filepath.Join(stepDir, "1.out.txt") {
+			t.Errorf("entry of run %d is not a passing test case with its step:\n%s", i+1, data)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package goldentest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Placeholders numbers the nondeterministic values scrubbed from the results
+// of a test case. Each distinct value of a kind gets the next number, in the
+// order values are first seen, so a value that appears in several steps of a
+// test case is replaced by the same placeholder every time.
+type Placeholders struct {
+	mu      sync.Mutex
+	numbers map[string]map[string]int
+}
+
+// NewPlaceholders returns an empty set of placeholders.
+func NewPlaceholders() *Placeholders {
+	return &Placeholders{numbers: map[string]map[string]int{}}
+}
+
+// Number returns the number of value among the values of kind, starting at 1.
+func (p *Placeholders) Number(kind, value string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	values, ok := p.numbers[kind]
+	if !ok {
+		values = map[string]int{}
+		p.numbers[kind] = values
+	}
+	n, ok := values[value]
+	if !ok {
+		n = len(values) + 1
+		values[value] = n
+	}
+	return n
+}
+
+// Placeholder returns the placeholder text for value among the values of kind,
+// e.g. "<uuid-2>".
+func (p *Placeholders) Placeholder(kind, value string) string {
+	return fmt.Sprintf("<%s-%d>", kind, p.Number(kind, value))
+}
+
+// Scrubber replaces nondeterministic parts of a result, such as timestamps or
+// generated IDs, before it is compared against and written to golden files.
+// It must not modify value, but return a scrubbed copy.
+//
+// Example:
+//
+//	config.Scrubbers = []goldentest.Scrubber[*Response]{
+//		func(value *Response, p *goldentest.Placeholders) *Response {
+//			scrubbed := *value
+//			scrubbed.RequestID = p.Placeholder("request", value.RequestID)
+//			return &scrubbed
+//		},
+//	}
+type Scrubber[T any] func(value T, placeholders *Placeholders) T
+
+// TextScrubber replaces every match of Pattern in formatted results and error
+// outputs with a numbered placeholder named after Kind, e.g. "<addr-1>".
+type TextScrubber struct {
+	Kind    string
+	Pattern *regexp.Regexp
+}
+
+// Scrub returns data with every match of Pattern replaced by its placeholder.
+func (s TextScrubber) Scrub(data []byte, placeholders *Placeholders) []byte {
+	return s.Pattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		return []byte(placeholders.Placeholder(s.Kind, string(match)))
+	})
+}
+
+// Common text scrubbers.
+var (
+	// ScrubUUIDs replaces UUIDs with <uuid-N>.
+	ScrubUUIDs = TextScrubber{
+		Kind:    "uuid",
+		Pattern: regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`),
+	}
+	// ScrubLocalAddresses replaces loopback addresses with a port, such as the
+	// address of a test server, with <addr-N>.
+	ScrubLocalAddresses = TextScrubber{
+		Kind:    "addr",
+		Pattern: regexp.MustCompile(`(?:127\.0\.0\.1|\[::1\]|localhost):\d+`),
+	}
+)
+
+// scrub applies the Scrubbers and then the TextScrubbers to result. Applying
+// TextScrubbers requires a round trip through the Formatter and Loader.
+func (config *TestConfig[T, F]) scrub(result T, placeholders *Placeholders) (T, error) {
+	for _, scrubber := range config.Scrubbers {
+		result = scrubber(result, placeholders)
+	}
+	if len(config.TextScrubbers) == 0 {
+		return result, nil
+	}
+
+	data, err := config.Formatter(result)
+	if err != nil {
+		return result, fmt.Errorf("failed to format result for scrubbing: %w", err)
+	}
+	scrubbed, err := config.Loader(config.scrubText(data, placeholders))
+	if err != nil {
+		return result, fmt.Errorf("failed to load scrubbed result: %w", err)
+	}
+	return scrubbed, nil
+}
+
+// scrubText applies the TextScrubbers to data.
+func (config *TestConfig[T, F]) scrubText(data []byte, placeholders *Placeholders) []byte {
+	for _, scrubber := range config.TextScrubbers {
+		data = scrubber.Scrub(data, placeholders)
+	}
+	return data
+}
+
+// ScrubProtoMessages returns a Scrubber that calls scrub on every message
+// named fullName nested anywhere in a result, including the result itself.
+// scrub modifies the message in place; the result is cloned first.
+func ScrubProtoMessages[T proto.Message](fullName protoreflect.FullName, scrub func(m protoreflect.Message, placeholders *Placeholders)) Scrubber[T] {
+	return func(value T, placeholders *Placeholders) T {
+		if !value.ProtoReflect().IsValid() {
+			return value
+		}
+		clone := proto.Clone(value).(T)
+		walkProto(clone.ProtoReflect(), func(m protoreflect.Message) bool {
+			if m.Descriptor().FullName() != fullName {
+				return false
+			}
+			scrub(m, placeholders)
+			return true
+		})
+		return clone
+	}
+}
+
+// ScrubProtoTimestamps returns a Scrubber that replaces every
+// google.protobuf.Timestamp with a placeholder: the Nth distinct timestamp of a
+// test case becomes N seconds after the Unix epoch.
+func ScrubProtoTimestamps[T proto.Message]() Scrubber[T] {
+	return ScrubProtoMessages[T]("google.protobuf.Timestamp", scrubSecondsNanos("timestamp"))
+}
+
+// ScrubProtoDurations returns a Scrubber that replaces every
+// google.protobuf.Duration with a placeholder: the Nth distinct duration of a
+// test case becomes N seconds long.
+func ScrubProtoDurations[T proto.Message]() Scrubber[T] {
+	return ScrubProtoMessages[T]("google.protobuf.Duration", scrubSecondsNanos("duration"))
+}
+
+// scrubSecondsNanos replaces the seconds and nanos fields shared by
+// google.protobuf.Timestamp and google.protobuf.Duration with a placeholder.
+func scrubSecondsNanos(kind string) func(m protoreflect.Message, placeholders *Placeholders) {
+	return func(m protoreflect.Message, placeholders *Placeholders) {
+		fields := m.Descriptor().Fields()
+		seconds, nanos := fields.ByName("seconds"), fields.ByName("nanos")
+		value := fmt.Sprintf("%d.%09d", m.Get(seconds).Int(), m.Get(nanos).Int())
+		m.Set(seconds, protoreflect.ValueOfInt64(int64(placeholders.Number(kind, value))))
+		m.Clear(nanos)
+	}
+}
+
+// ScrubProtoStrings returns a Scrubber that replaces every match of pattern in
+// every string field of a result with a numbered placeholder named after kind.
+func ScrubProtoStrings[T proto.Message](kind string, pattern *regexp.Regexp) Scrubber[T] {
+	scrubber := TextScrubber{Kind: kind, Pattern: pattern}
+	scrubString := func(v protoreflect.Value, placeholders *Placeholders) protoreflect.Value {
+		return protoreflect.ValueOfString(string(scrubber.Scrub([]byte(v.String()), placeholders)))
+	}
+	return func(value T, placeholders *Placeholders) T {
+		if !value.ProtoReflect().IsValid() {
+			return value
+		}
+		clone := proto.Clone(value).(T)
+		walkProto(clone.ProtoReflect(), func(m protoreflect.Message) bool {
+			fields := m.Descriptor().Fields()
+			for i := 0; i < fields.Len(); i++ {
+				fd := fields.Get(i)
+				if !m.Has(fd) {
+					continue
+				}
+				switch {
+				case fd.IsMap():
+					// Map keys are left alone; only values are scrubbed.
+					if fd.MapValue().Kind() == protoreflect.StringKind {
+						mp := m.Mutable(fd).Map()
+						for _, k := range sortedMapKeys(mp) {
+							mp.Set(k, scrubString(mp.Get(k), placeholders))
+						}
+					}
+				case fd.Kind() != protoreflect.StringKind:
+				case fd.IsList():
+					list := m.Mutable(fd).List()
+					for j := 0; j < list.Len(); j++ {
+						list.Set(j, scrubString(list.Get(j), placeholders))
+					}
+				default:
+					m.Set(fd, scrubString(m.Get(fd), placeholders))
+				}
+			}
+			return false
+		})
+		return clone
+	}
+}
+
+// walkProto calls fn on m and every message nested in it, in field
+// declaration order and map key order, so that placeholders are numbered
+// deterministically. If fn returns
+// true, the messages nested in the message it was called on are skipped.
+func walkProto(m protoreflect.Message, fn func(m protoreflect.Message) bool) {
+	if fn(m) {
+		return
+	}
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !m.Has(fd) {
+			continue
+		}
+		switch {
+		case fd.IsList():
+			if fd.Message() == nil {
+				continue
+			}
+			list := m.Get(fd).List()
+			for j := 0; j < list.Len(); j++ {
+				walkProto(list.Get(j).Message(), fn)
+			}
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				continue
+			}
+			mp := m.Get(fd).Map()
+			for _, k := range sortedMapKeys(mp) {
+				walkProto(mp.Get(k).Message(), fn)
+			}
+		case fd.Message() != nil:
+			walkProto(m.Get(fd).Message(), fn)
+		}
+	}
+}
+
+// sortedMapKeys returns the keys of mp in a deterministic order.
+func sortedMapKeys(mp protoreflect.Map) []protoreflect.MapKey {
+	var keys []protoreflect.MapKey
+	mp.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
@@ -0,0 +1,94 @@
+package goldentest
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestTextScrubber(t *testing.T) {
+	placeholders := NewPlaceholders()
+	in := "a 3f2504e0-4f89-11d3-9a0c-0305e82c3301 b 9a8b7c6d-0000-1111-2222-333344445555 c 3f2504e0-4f89-11d3-9a0c-0305e82c3301"
+	want := "a <uuid-1> b <uuid-2> c <uuid-1>"
+	if got := string(ScrubUUIDs.Scrub([]byte(in), placeholders)); got != want {
+		t.Errorf("Scrub = %q, want %q", got, want)
+	}
+	if got, want := string(ScrubLocalAddresses.Scrub([]byte("dial 127.0.0.1:41235"), placeholders)), "dial <addr-1>"; got != want {
+		t.Errorf("Scrub = %q, want %q", got, want)
+	}
+}
+
+func TestScrubProtoTimestamps(t *testing.T) {
+	placeholders := NewPlaceholders()
+	scrub := ScrubProtoTimestamps[*timestamppb.Timestamp]()
+
+	first := &timestamppb.Timestamp{Seconds: 1700000000, Nanos: 5}
+	second := &timestamppb.Timestamp{Seconds: 1700000100}
+	for _, tt := range []struct {
+		in   *timestamppb.Timestamp
+		want *timestamppb.Timestamp
+	}{
+		{in: first, want: &timestamppb.Timestamp{Seconds: 1}},
+		{in: second, want: &timestamppb.Timestamp{Seconds: 2}},
+		{in: first, want: &timestamppb.Timestamp{Seconds: 1}},
+	} {
+		if diff := cmp.Diff(tt.want, scrub(tt.in, placeholders), protocmp.Transform()); diff != "" {
+			t.Errorf("scrubbed timestamp mismatch (-want +got):\n%s", diff)
+		}
+	}
+	if first.Seconds != 1700000000 {
+		t.Error("scrubber modified its input")
+	}
+}
+
+func TestScrubProtoStrings(t *testing.T) {
+	in, err := structpb.NewStruct(map[string]any{
+		"id":     "req-123",
+		"nested": map[string]any{"parent": "req-456", "again": "req-123"},
+		"list":   []any{"req-456", "static"},
+	})
+	if err != nil {
+		t.Fatalf("NewStruct: %v", err)
+	}
+	want, err := structpb.NewStruct(map[string]any{
+		"id":     "<request-1>",
+		"nested": map[string]any{"parent": "<request-2>", "again": "<request-1>"},
+		"list":   []any{"<request-2>", "static"},
+	})
+	if err != nil {
+		t.Fatalf("NewStruct: %v", err)
+	}
+
+	scrub := ScrubProtoStrings[*structpb.Struct]("request", regexp.MustCompile(`req-\d+`))
+	if diff := cmp.Diff(want, scrub(in, NewPlaceholders()), protocmp.Transform()); diff != "" {
+		t.Errorf("scrubbed struct mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunStepTestsScrubbers(t *testing.T) {
+	fsys := contents(map[string]string{
+		"session/1.in.hcl":   "login",
+		"session/1.out.json": "session <uuid-1>",
+		"session/2.in.hcl":   "refresh",
+		"session/2.out.json": "session <uuid-1> replaced by <uuid-2>",
+	})
+
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		TextScrubbers:    []TextScrubber{ScrubUUIDs},
+		StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+			if string(stepFile.Data) == "login" {
+				return "session 6ba7b810-9dad-11d1-80b4-00c04fd430c8", nil
+			}
+			return "session 6ba7b810-9dad-11d1-80b4-00c04fd430c8 replaced by 6ba7b811-9dad-11d1-80b4-00c04fd430c8", nil
+		},
+	}
+
+	config.RunTestsFS(t, fsys, ".")
+}
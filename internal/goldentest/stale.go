@@ -0,0 +1,76 @@
+package goldentest
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// outputKey returns the test case (one-shot mode) or step (step mode) that
+// the output file name belongs to, and whether name is an output file at all.
+func (config *TestConfig[T, F]) outputKey(name string) (string, bool) {
+	for _, ext := range []string{config.SuccessOutputExt, config.ErrorOutputExt} {
+		if ext == "" {
+			continue
+		}
+		if key, ok := strings.CutSuffix(name, ".out"+ext); ok {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// staleOutputs returns the sorted names of the output files in files that no
+// test case reads: files whose input was renamed or deleted, and files next to
+// the output that is actually used for the same case, such as a success output
+// left behind by a case that now fails. expected maps each case or step, as
+// returned by outputKey, to the names of the output files it may have.
+func (config *TestConfig[T, F]) staleOutputs(files caseFiles, expected map[string][]string) ([]string, error) {
+	names, err := files.names()
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []string
+	for _, name := range names {
+		key, ok := config.outputKey(name)
+		if !ok || slices.Contains(expected[key], name) {
+			continue
+		}
+		stale = append(stale, name)
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
+
+// caseName returns the name against which -update test case patterns are
+// matched for the stale output file name found while running t.
+func caseName(t *testing.T, name string) string {
+	return t.Name() + "/" + name
+}
+
+// pruneStaleOutputs fails t for every stale output file in files, as returned
+// by staleOutputs. With -update, the stale files are deleted instead.
+func (config *TestConfig[T, F]) pruneStaleOutputs(t *testing.T, files caseFiles, expected map[string][]string) {
+	stale, err := config.staleOutputs(files, expected)
+	if err != nil {
+		t.Errorf("failed to look for stale golden files: %v", err)
+		return
+	}
+
+	for _, name := range stale {
+		path := files.path(name)
+		if Update.rewrites(caseName(t, name)) {
+			removeGolden(t, files, name)
+			continue
+		}
+
+		key, _ := config.outputKey(name)
+		if want, ok := expected[key]; ok {
+			t.Errorf("stale golden file %s: the test case uses %s instead; run with -update to remove it", path, strings.Join(want, " or "))
+		} else {
+			t.Errorf("stale golden file %s has no matching input; run with -update to remove it", path)
+		}
+	}
+}
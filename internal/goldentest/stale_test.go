@@ -0,0 +1,82 @@
+package goldentest
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// namedFiles returns an fs.FS with the named files, each containing its name.
+func namedFiles(names ...string) memFS {
+	fsys := memFS{}
+	for _, name := range names {
+		fsys[name] = &fstest.MapFile{Data: []byte(name)}
+	}
+	return fsys
+}
+
+func TestStaleOutputs(t *testing.T) {
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		ErrorOutputExt:   ".txt",
+		ErrorFunc:        func(err error) []byte { return []byte(err.Error()) },
+		ErrorPrefix:      "error_",
+	}
+
+	t.Run("step", func(t *testing.T) {
+		fsys := namedFiles(
+			"1.in.hcl", "1.out.json",
+			"2.in.hcl", "2.out.txt",
+			"7.out.json", "9.out.txt",
+			"notes.md",
+		)
+		stepFiles := []StepFile{{Step: 1}, {Step: 2}}
+
+		stale, err := config.staleOutputs(dirFiles{fsys: fsys, dir: "."}, config.stepOutputs(stepFiles))
+		if err != nil {
+			t.Fatalf("staleOutputs: %v", err)
+		}
+		if diff := cmp.Diff([]string{"7.out.json", "9.out.txt"}, stale); diff != "" {
+			t.Errorf("stale outputs mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("one-shot", func(t *testing.T) {
+		fsys := namedFiles(
+			"simple.hcl", "simple.out.json", "simple.out.txt",
+			"error_case.hcl", "error_case.out.txt",
+			"renamed.out.json",
+		)
+		expected := map[string][]string{
+			"simple":     {"simple.out.json"},
+			"error_case": {"error_case.out.txt"},
+		}
+
+		stale, err := config.staleOutputs(dirFiles{fsys: fsys, dir: "."}, expected)
+		if err != nil {
+			t.Fatalf("staleOutputs: %v", err)
+		}
+		if diff := cmp.Diff([]string{"renamed.out.json", "simple.out.txt"}, stale); diff != "" {
+			t.Errorf("stale outputs mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("prune on update", func(t *testing.T) {
+		fsys := namedFiles("1.in.hcl", "1.out.json", "2.out.json")
+
+		defer func(update UpdateFlag) { *Update = update }(*Update)
+		*Update = UpdateFlag{Mode: UpdateAll}
+		config.pruneStaleOutputs(t, dirFiles{fsys: fsys, dir: "."}, config.stepOutputs([]StepFile{{Step: 1}}))
+
+		names, err := fs.Glob(fsys, "*")
+		if err != nil {
+			t.Fatalf("failed to list files: %v", err)
+		}
+		if diff := cmp.Diff([]string{"1.in.hcl", "1.out.json"}, names); diff != "" {
+			t.Errorf("files after pruning mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
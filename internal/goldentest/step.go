@@ -1,15 +1,18 @@
 package goldentest
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io/fs"
+	"path"
 	"path/filepath"
+	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -38,114 +41,360 @@ type StepTestFunc[T, F any] func(ctx context.Context, fixture F, stepFile StepFi
 type StepFile struct {
 	// Step is the 1-based step number
 	Step int
-	// FilePath is the full path to the step file
+	// FilePath is the full path to the step file; for an archive case, the path of the
+	// archive joined with the section name
 	FilePath string
-	// Data is the content of the step file
+	// Data is the content of the step file, with references to captured values expanded
 	Data []byte
 }
 
-// runStepTests runs golden file tests in step mode for all directories in the specified directory
-func (config *TestConfig[T, F]) runStepTests(t *testing.T, dir string) {
-	entries, err := os.ReadDir(dir)
+// stepCase is a step test case: a directory of step files, or a txtar
+// archive of them. With Recursive, it may instead be a group of test cases.
+type stepCase struct {
+	name string
+	// open loads the files of the case and validates their layout.
+	open func() (caseFiles, error)
+	// group is the directory of a group of test cases, or "" for a test case.
+	group string
+}
+
+// stepCases returns the step test cases in dir of fsys, sorted by name. A case
+// may be a subdirectory, or a txtar archive named after the case with
+// ArchiveExt. Other files are ignored. With Recursive, subdirectories without
+// step input files are groups.
+func (config *TestConfig[T, F]) stepCases(fsys fs.FS, dir string) ([]stepCase, error) {
+	entries, err := fs.ReadDir(fsys, dir)
 	if err != nil {
-		t.Fatalf("failed to read testdata directory: %v", err)
+		return nil, err
 	}
 
+	var cases []stepCase
+	kinds := map[string]string{}
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		name := path.Join(dir, entry.Name())
+		var c stepCase
+		switch {
+		case entry.IsDir() && config.Recursive:
+			isCase, err := config.hasStepInputs(fsys, name)
+			if err != nil {
+				return nil, err
+			}
+			if !isCase {
+				c = stepCase{name: entry.Name(), group: name}
+				break
+			}
+			fallthrough
+		case entry.IsDir():
+			c = stepCase{name: entry.Name(), open: func() (caseFiles, error) { return openStepDir(fsys, name) }}
+		case strings.HasSuffix(entry.Name(), ArchiveExt):
+			c = stepCase{name: strings.TrimSuffix(entry.Name(), ArchiveExt), open: func() (caseFiles, error) { return openArchive(fsys, name) }}
+		default:
 			continue
 		}
+		if other, ok := kinds[c.name]; ok {
+			return nil, fmt.Errorf("test case %s is defined by both %s and %s", c.name, other, entry.Name())
+		}
+		kinds[c.name] = entry.Name()
+		cases = append(cases, c)
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].name < cases[j].name })
+	return cases, nil
+}
 
-		t.Run(entry.Name(), func(t *testing.T) {
-			// Set up fixture for this test case
-			var fixture F
-			var setUpErr error
-			if config.SetUp != nil {
-				fixture, setUpErr = config.SetUp(t)
-				if setUpErr != nil {
-					t.Fatalf("SetUp failed for test %s: %v", entry.Name(), setUpErr)
-				}
-			}
+// hasStepInputs reports whether dir of fsys contains step input files, which
+// makes it a test case rather than a group of test cases.
+func (config *TestConfig[T, F]) hasStepInputs(fsys fs.FS, dir string) (bool, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".in"+config.InputExt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
 
-			// Ensure teardown runs even if test fails
-			defer func() {
-				if config.TearDown != nil {
-					if tearDownErr := config.TearDown(t, fixture); tearDownErr != nil {
-						t.Errorf("TearDown failed for test %s: %v", entry.Name(), tearDownErr)
-					}
-				}
-			}()
+// runStepTests runs golden file tests in step mode for all step test cases in
+// the specified directory of fsys
+func (r *runner[T, F]) runStepTests(t *testing.T, fsys fs.FS, dir string) {
+	config := r.config
+	cases, err := config.stepCases(fsys, dir)
+	if err != nil {
+		t.Fatalf("failed to read testdata directory: %v", err)
+	}
+
+	for _, c := range cases {
+		if c.group != "" {
+			r.runGroup(t, c.name, func(t *testing.T) { r.runStepTests(t, fsys, c.group) })
+			continue
+		}
 
-			stepDir := filepath.Join(dir, entry.Name())
-			stepFiles, validateErr := validateAndLoadStepFiles[T, F](stepDir, config)
+		var files caseFiles
+		load := func() (*CaseMetadata, error) {
+			var err error
+			if files, err = c.open(); err != nil {
+				return nil, err
+			}
+			return loadMetadata(files, MetadataFile)
+		}
+		r.runCase(t, c.name, load, func(t *testing.T, meta *CaseMetadata, fixture F, discard func()) {
+			stepFiles, validateErr := config.loadStepFiles(files)
 			if validateErr != nil {
-				t.Fatalf("failed to validate step directory %s: %v", entry.Name(), validateErr)
+				t.Fatalf("failed to validate test case %s: %v", c.name, validateErr)
+			}
+			config.pruneStaleOutputs(t, files, config.stepOutputs(stepFiles))
+			if err := config.validateCaptures(stepFiles); err != nil {
+				t.Fatalf("invalid captures in test case %s: %v", c.name, err)
 			}
 
-			var results []T
-			var testErr error
+			// In an error case, the final step fails unless its outputs say otherwise
+			errorCase := config.errorHandling() && strings.HasPrefix(c.name, config.ErrorPrefix)
 
-			// Execute stepTestFunc for each step file
-			for _, stepFile := range stepFiles {
-				result, err := config.StepTestFunc(t.Context(), fixture, stepFile)
-				if err != nil {
-					testErr = err
-					break
-				}
-				results = append(results, result)
-			}
+			ctx, cancel, caseDeadline := config.caseContext(t, meta.Timeout)
+			defer cancel()
+			placeholders := NewPlaceholders()
+			captured := map[string]string{}
 
-			// Check if error handling is configured
-			errorHandlingEnabled := config.ErrorFunc != nil
+			// Steps after the last one selected by -run would not be checked, so
+			// they do not run at all
+			last := lastSelectedStep(t, stepFiles)
 
-			if errorHandlingEnabled && strings.HasPrefix(entry.Name(), config.ErrorPrefix) {
-				// This is an error test case - only test the final result
-				if testErr == nil {
-					t.Errorf("expected error for test %s, but got none", entry.Name())
-					return
+			// Execute stepTestFunc for each step file in its own subtest
+			for _, stepFile := range stepFiles[:last] {
+				stepFile, expandErr := expandCaptures(stepFile, captured)
+				if expandErr != nil {
+					t.Fatalf("step %d (%s): %v", stepFile.Step, filepath.Base(stepFile.FilePath), expandErr)
+				}
+
+				errorByDefault := errorCase && stepFile.Step == len(stepFiles)
+				var outcome stepOutcome[T]
+				ran, next := false, false
+				caseT := t
+				t.Run(stepName(stepFile.Step), func(t *testing.T) {
+					ran = true
+					reportStep(caseT, t)
+					var elapsed time.Duration
+					outcome, elapsed = config.execStep(t, ctx, caseDeadline, fixture, stepFile, discard)
+					reportDuration(t, elapsed)
+					next = config.checkStep(t, files, stepFile.Step, errorByDefault, outcome.result, outcome.err, placeholders)
+				})
+				if !ran {
+					// -run does not select this step, but the selected steps
+					// after it depend on its effects, so it runs unchecked
+					outcome, _ = config.execStep(t, ctx, caseDeadline, fixture, stepFile, discard)
+					next = true
 				}
-				// Test error with final step number as filename
-				finalStepNum := len(stepFiles)
-				errorFile := fmt.Sprintf("%d.out%s", finalStepNum, config.ErrorOutputExt)
-				config.testErrorCaseStep(t, stepDir, errorFile, testErr, config.ErrorFunc)
-			} else {
-				// This is a success test case (or error handling is disabled)
-				if testErr != nil {
-					if !errorHandlingEnabled {
-						t.Errorf("test failed for %s: %v", entry.Name(), testErr)
-						return
-					}
-					// Error handling is enabled but this isn't an error test case
-					t.Errorf("unexpected error for test %s: %v", entry.Name(), testErr)
+				if !next {
 					return
 				}
-				config.testSuccessCaseSteps(t, stepDir, stepFiles, results)
+				if outcome.err != nil {
+					continue
+				}
+				if err := config.captureValues(captured, stepFile, outcome.result); err != nil {
+					t.Fatalf("step %d (%s): %v", stepFile.Step, filepath.Base(stepFile.FilePath), err)
+				}
 			}
 		})
 	}
 }
 
-func (config *TestConfig[T, F]) testErrorCaseStep(t *testing.T, stepDir, errorFile string, testErr error, errorFunc ErrorFunc) {
-	expectedError, readErr := os.ReadFile(filepath.Join(stepDir, errorFile))
-	if readErr != nil {
-		t.Logf("failed to read expected error output file: %v", readErr)
+// stepName returns the name of the subtest of step.
+func stepName(step int) string {
+	return fmt.Sprintf("step_%d", step)
+}
+
+// lastSelectedStep returns the number of the last of stepFiles whose subtest
+// of t is selected by -run, or 0 if there is none.
+func lastSelectedStep(t *testing.T, stepFiles []StepFile) int {
+	last := 0
+	for _, stepFile := range stepFiles {
+		if testSelected(t.Name() + "/" + stepName(stepFile.Step)) {
+			last = stepFile.Step
+		}
+	}
+	return last
+}
+
+// execStep calls StepTestFunc for stepFile with a context limited by
+// StepTimeout, and returns its outcome and how long it took. It fails t if the
+// step exceeds a deadline, panics or hangs, calling discard first in the last
+// two cases since the step may have left fixture broken or still be using it.
+func (config *TestConfig[T, F]) execStep(t *testing.T, ctx context.Context, caseDeadline string, fixture F, stepFile StepFile, discard func()) (stepOutcome[T], time.Duration) {
+	stepCtx, cancelStep := ctx, context.CancelFunc(func() {})
+	if config.StepTimeout > 0 {
+		stepCtx, cancelStep = context.WithTimeout(ctx, config.StepTimeout)
+	}
+	defer cancelStep()
+
+	start := time.Now()
+	outcome, returned := config.runStep(stepCtx, fixture, stepFile)
+	elapsed := time.Since(start)
+
+	switch {
+	case !returned:
+		discard()
+		t.Fatalf("step %d (%s) hung: it did not return within %s after its context was done", stepFile.Step, filepath.Base(stepFile.FilePath), hangGracePeriod)
+	case outcome.panicked != nil:
+		discard()
+		t.Fatalf("step %d (%s) panicked: %v\n%s", stepFile.Step, filepath.Base(stepFile.FilePath), outcome.panicked, outcome.stack)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		t.Fatalf("step %d (%s) was running when the test case exceeded %s", stepFile.Step, filepath.Base(stepFile.FilePath), caseDeadline)
+	case errors.Is(stepCtx.Err(), context.DeadlineExceeded):
+		t.Fatalf("step %d (%s) exceeded the step timeout of %s", stepFile.Step, filepath.Base(stepFile.FilePath), config.StepTimeout)
+	}
+	return outcome, elapsed
+}
+
+// hangGracePeriod is how long a step may keep running after its context is
+// done before it is reported as hung.
+const hangGracePeriod = time.Second
+
+// stepOutcome is what a call to StepTestFunc returned, or the value it
+// panicked with and the stack of the panic.
+type stepOutcome[T any] struct {
+	result   T
+	err      error
+	panicked any
+	stack    []byte
+}
+
+// caseContext returns the context shared by the steps of the test case t and
+// a description of its deadline. The deadline is timeout, the timeout from the
+// test case's metadata, or CaseTimeout if it is zero, or shortly before the
+// -test.timeout deadline so that a hung step is reported before the test
+// binary panics.
+func (config *TestConfig[T, F]) caseContext(t *testing.T, timeout time.Duration) (context.Context, context.CancelFunc, string) {
+	if timeout <= 0 {
+		timeout = config.CaseTimeout
+	}
+	ctx := t.Context()
+	if deadline, ok := t.Deadline(); ok {
+		deadline = deadline.Add(-2 * hangGracePeriod)
+		if timeout <= 0 || time.Until(deadline) < timeout {
+			ctx, cancel := context.WithDeadline(ctx, deadline)
+			return ctx, cancel, "the -test.timeout deadline"
+		}
+	}
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		return ctx, cancel, fmt.Sprintf("the case timeout of %s", timeout)
+	}
+	return ctx, func() {}, ""
+}
+
+// runStep calls StepTestFunc for stepFile. If ctx has a deadline, the step
+// runs in its own goroutine, and runStep gives up on it and returns false if it
+// has not returned hangGracePeriod after ctx is done.
+func (config *TestConfig[T, F]) runStep(ctx context.Context, fixture F, stepFile StepFile) (stepOutcome[T], bool) {
+	if _, ok := ctx.Deadline(); !ok {
+		return config.callStep(ctx, fixture, stepFile), true
+	}
+
+	done := make(chan stepOutcome[T], 1)
+	go func() {
+		done <- config.callStep(ctx, fixture, stepFile)
+	}()
+	select {
+	case outcome := <-done:
+		return outcome, true
+	case <-ctx.Done():
+	}
+	select {
+	case outcome := <-done:
+		return outcome, true
+	case <-time.After(hangGracePeriod):
+		return stepOutcome[T]{}, false
+	}
+}
+
+// callStep calls StepTestFunc for stepFile and recovers a panic into the
+// outcome, since a panic in the goroutine of runStep would crash the test
+// binary without running cleanups.
+func (config *TestConfig[T, F]) callStep(ctx context.Context, fixture F, stepFile StepFile) (outcome stepOutcome[T]) {
+	defer func() {
+		if v := recover(); v != nil {
+			outcome = stepOutcome[T]{panicked: v, stack: debug.Stack()}
+		}
+	}()
+	result, err := config.StepTestFunc(ctx, fixture, stepFile)
+	return stepOutcome[T]{result: result, err: err}
+}
+
+// checkStep compares the outcome of a step against its golden files and
+// reports whether the following steps should run.
+//
+// A step is expected to fail if it has an error output file and to succeed if
+// it has a success output file, so failing and succeeding steps can be
+// interleaved. A step with neither is expected to succeed, unless
+// errorByDefault is set, in which case succeeding fails t without recording
+// the result. A step that fails unexpectedly stops the test case,
+// since the following steps would run against an unexpected state. That
+// includes a step with neither output, even with -update: an error is only
+// recorded for a step that is expected to fail, by an error output file or
+// errorByDefault, so that -update never turns an unexpected failure into an
+// expectation.
+func (config *TestConfig[T, F]) checkStep(t *testing.T, files caseFiles, step int, errorByDefault bool, result T, err error, placeholders *Placeholders) bool {
+	successName := fmt.Sprintf("%d.out%s", step, config.SuccessOutputExt)
+	if err != nil && !config.errorHandling() {
+		t.Errorf("step %d failed: %v", step, err)
+		return false
+	}
+	if !config.errorHandling() {
+		config.testSuccessStep(t, files, successName, step, result, placeholders)
+		return true
+	}
+
+	errorName := fmt.Sprintf("%d.out%s", step, config.ErrorOutputExt)
+	successPath, errorPath := files.path(successName), files.path(errorName)
+	hasSuccess, hasError := files.has(successName), files.has(errorName)
+	rewrite := Update.rewrites(t.Name())
+	if hasSuccess && hasError && !rewrite {
+		t.Errorf("step %d has both a success output %s and an error output %s; delete the wrong one or run with -update", step, successPath, errorPath)
+		return false
 	}
 
-	actualError := errorFunc(testErr)
-	if !bytes.Equal(expectedError, actualError) {
-		if *Update {
-			if writeErr := os.WriteFile(filepath.Join(stepDir, errorFile), actualError, 0644); writeErr != nil {
-				t.Errorf("failed to update error output file: %v", writeErr)
+	if err != nil {
+		if !hasSuccess && !hasError && !errorByDefault {
+			t.Errorf("unexpected error for step %d: %v", step, err)
+			return false
+		}
+		if hasSuccess {
+			if !rewrite {
+				t.Errorf("unexpected error for step %d (%s expects it to succeed): %v", step, successPath, err)
+				return false
 			}
-			return
+			removeGolden(t, files, successName)
+		}
+		config.testErrorStep(t, files, errorName, err, placeholders)
+		return true
+	}
+
+	if hasError {
+		if !rewrite {
+			t.Errorf("expected step %d to fail as recorded in %s, but it succeeded", step, errorPath)
+			return true
 		}
-		t.Errorf("error output mismatch for file %s:\nExpected:\n%s\nGot:\n%s", errorFile, expectedError, actualError)
+		removeGolden(t, files, errorName)
+	} else if errorByDefault && !hasSuccess {
+		t.Errorf("expected step %d to fail as the final step of an error case, but it succeeded; add %s if it should succeed", step, successPath)
+		return true
 	}
+	config.testSuccessStep(t, files, successName, step, result, placeholders)
+	return true
+}
+
+// testErrorStep compares the error of a step against the named error output.
+func (config *TestConfig[T, F]) testErrorStep(t *testing.T, files caseFiles, name string, testErr error, placeholders *Placeholders) {
+	config.testErrorOutput(t, files, name, name, testErr, placeholders)
 }
 
-func (config *TestConfig[T, F]) testSuccessCaseSteps(t *testing.T, stepDir string, stepFiles []StepFile, results []T) {
-	if len(results) != len(stepFiles) {
-		t.Errorf("expected %d results, got %d", len(stepFiles), len(results))
+// testSuccessStep compares the result of a step against the named success output.
+func (config *TestConfig[T, F]) testSuccessStep(t *testing.T, files caseFiles, name string, step int, result T, placeholders *Placeholders) {
+	result, scrubErr := config.scrub(result, placeholders)
+	if scrubErr != nil {
+		t.Errorf("step %d: %v", step, scrubErr)
 		return
 	}
 
@@ -153,89 +402,124 @@ func (config *TestConfig[T, F]) testSuccessCaseSteps(t *testing.T, stepDir strin
 	diffOpts = append(diffOpts, cmpopts.EquateEmpty())
 	diffOpts = append(diffOpts, config.DiffOpts...)
 
-	for i, result := range results {
-		stepNum := stepFiles[i].Step
-		outputFile := fmt.Sprintf("%d.out%s", stepNum, config.SuccessOutputExt)
-		outputPath := filepath.Join(stepDir, outputFile)
+	expectedData, ok := readGolden(t, files, name, func() ([]byte, error) { return config.Formatter(result) })
+	if !ok {
+		return
+	}
 
-		expectedData, readErr := os.ReadFile(outputPath)
-		if readErr != nil {
-			t.Logf("failed to read expected output file %s: %v", outputFile, readErr)
-		}
+	// Load expected value from golden file
+	expected, loadErr := config.Loader(expectedData)
+	if loadErr != nil {
+		t.Errorf("failed to load expected value from %s: %v", name, loadErr)
+		return
+	}
 
-		// Load expected value from golden file
-		expected, loadErr := config.Loader(expectedData)
-		if loadErr != nil {
-			t.Errorf("failed to load expected value from %s: %v", outputFile, loadErr)
+	if diff := cmp.Diff(expected, result, diffOpts...); diff != "" {
+		report := config.mismatchReport(files.path(name), expectedData, result, diff)
+		reportDiff(t, report)
+		if Update.rewrites(t.Name()) {
+			// Format the actual result for writing to golden file
+			actualData, formatErr := config.Formatter(result)
+			if formatErr != nil {
+				t.Errorf("failed to format result for step %d: %v", step, formatErr)
+				return
+			}
+
+			updateGolden(t, files, name, actualData)
 			return
 		}
+		writePending(t, files, name, func() ([]byte, error) { return config.Formatter(result) })
+		t.Errorf("output mismatch for step %d:\n%s", step, report)
+		return
+	}
+	config.checkFormatted(t, files, name, expectedData, result)
+}
 
-		if diff := cmp.Diff(expected, result, diffOpts...); diff != "" {
-			if *Update {
-				// Format the actual result for writing to golden file
-				actualData, formatErr := config.Formatter(result)
-				if formatErr != nil {
-					t.Errorf("failed to format result for step %d: %v", stepNum, formatErr)
-					return
-				}
-
-				if writeErr := os.WriteFile(outputPath, actualData, 0644); writeErr != nil {
-					t.Errorf("failed to update output file %s: %v", outputFile, writeErr)
-				}
-				continue
-			}
-			t.Errorf("output mismatch for step %d (-expected +got):\n%s", stepNum, diff)
+// stepOutputs returns the output files each step may have, keyed by step
+// number: a success output, or an error output if error handling is enabled.
+func (config *TestConfig[T, F]) stepOutputs(stepFiles []StepFile) map[string][]string {
+	outputs := map[string][]string{}
+	for _, stepFile := range stepFiles {
+		key := strconv.Itoa(stepFile.Step)
+		outputs[key] = append(outputs[key], fmt.Sprintf("%d.out%s", stepFile.Step, config.SuccessOutputExt))
+		if config.errorHandling() {
+			outputs[key] = append(outputs[key], fmt.Sprintf("%d.out%s", stepFile.Step, config.ErrorOutputExt))
 		}
 	}
+	return outputs
 }
 
 // validateAndLoadStepFiles validates that a directory contains a valid sequence of step files
 // and loads their content. Returns an error if the sequence is invalid or if any files are unexpected.
 func validateAndLoadStepFiles[T, F any](stepDir string, config *TestConfig[T, F]) ([]StepFile, error) {
-	entries, err := os.ReadDir(stepDir)
+	files, err := openStepDir(DirFS(stepDir), ".")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read step directory: %w", err)
+		return nil, err
 	}
+	return config.loadStepFiles(files)
+}
 
-	var stepFiles []StepFile
-	expectedStep := 1
-
-	// Parse and collect all files with the correct extension
+// openStepDir returns the files of the step directory stepDir in fsys, which
+// must not have subdirectories.
+func openStepDir(fsys fs.FS, stepDir string) (caseFiles, error) {
+	entries, err := fs.ReadDir(fsys, stepDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read step directory: %w", err)
+	}
 	for _, entry := range entries {
 		if entry.IsDir() {
 			return nil, fmt.Errorf("unexpected subdirectory %s in step directory", entry.Name())
 		}
+	}
+	return dirFiles{fsys: fsys, dir: stepDir}, nil
+}
+
+// loadStepFiles validates that files contain a valid sequence of step files
+// and loads their content. Returns an error if the sequence is invalid or if
+// any files are unexpected.
+func (config *TestConfig[T, F]) loadStepFiles(files caseFiles) ([]StepFile, error) {
+	names, err := files.names()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list step files: %w", err)
+	}
 
+	var stepFiles []StepFile
+	expectedStep := 1
+
+	// Parse and collect all files with the correct extension
+	for _, name := range names {
 		// Skip output files - check for both success and error output extensions with .out prefix
-		if strings.HasSuffix(entry.Name(), ".out"+config.SuccessOutputExt) || strings.HasSuffix(entry.Name(), ".out"+config.ErrorOutputExt) {
+		if strings.HasSuffix(name, ".out"+config.SuccessOutputExt) || strings.HasSuffix(name, ".out"+config.ErrorOutputExt) {
+			continue
+		}
+		if name == MetadataFile || strings.HasSuffix(name, PendingExt) {
 			continue
 		}
 
-		if !strings.HasSuffix(entry.Name(), ".in"+config.InputExt) {
-			return nil, fmt.Errorf("unexpected file %s with wrong extension (expected %s)", entry.Name(), ".in"+config.InputExt)
+		if !strings.HasSuffix(name, ".in"+config.InputExt) {
+			return nil, fmt.Errorf("unexpected file %s with wrong extension (expected %s)", name, ".in"+config.InputExt)
 		}
 
 		// Extract step number from filename
-		baseName := strings.TrimSuffix(entry.Name(), ".in"+config.InputExt)
+		baseName := strings.TrimSuffix(name, ".in"+config.InputExt)
 		stepNum, parseErr := strconv.Atoi(baseName)
 		if parseErr != nil {
-			return nil, fmt.Errorf("invalid step filename %s: must be a number", entry.Name())
+			return nil, fmt.Errorf("invalid step filename %s: must be a number", name)
 		}
 
 		if stepNum <= 0 {
-			return nil, fmt.Errorf("invalid step number %d in filename %s: must be positive", stepNum, entry.Name())
+			return nil, fmt.Errorf("invalid step number %d in filename %s: must be positive", stepNum, name)
 		}
 
 		// Load file content
-		filePath := filepath.Join(stepDir, entry.Name())
-		data, readErr := os.ReadFile(filePath)
+		data, readErr := files.read(name)
 		if readErr != nil {
-			return nil, fmt.Errorf("failed to read step file %s: %w", entry.Name(), readErr)
+			return nil, fmt.Errorf("failed to read step file %s: %w", name, readErr)
 		}
 
 		stepFiles = append(stepFiles, StepFile{
 			Step:     stepNum,
-			FilePath: filePath,
+			FilePath: files.path(name),
 			Data:     data,
 		})
 	}
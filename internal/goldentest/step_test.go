@@ -2,9 +2,22 @@ package goldentest
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestValidateAndLoadStepFiles(t *testing.T) {
@@ -23,9 +36,9 @@ func TestValidateAndLoadStepFiles(t *testing.T) {
 		}
 
 		files := map[string]string{
-			"1.hcl": "step 1 content",
-			"2.hcl": "step 2 content",
-			"3.hcl": "step 3 content",
+			"1.in.hcl": "step 1 content",
+			"2.in.hcl": "step 2 content",
+			"3.in.hcl": "step 3 content",
 		}
 
 		for filename, content := range files {
@@ -35,6 +48,7 @@ func TestValidateAndLoadStepFiles(t *testing.T) {
 		}
 
 		config := &TestConfig[string, struct{}]{
+			InputExt:         ".hcl",
 			SuccessOutputExt: ".json",
 			ErrorOutputExt:   ".txt",
 		}
@@ -52,7 +66,7 @@ func TestValidateAndLoadStepFiles(t *testing.T) {
 			if stepFile.Step != expectedStep {
 				t.Errorf("expected step %d, got %d", expectedStep, stepFile.Step)
 			}
-			expectedContent := files[stepFile.FilePath[len(stepFile.FilePath)-5:]] // last 5 chars should be "X.hcl"
+			expectedContent := files[filepath.Base(stepFile.FilePath)]
 			if string(stepFile.Data) != expectedContent {
 				t.Errorf("expected content %q, got %q", expectedContent, string(stepFile.Data))
 			}
@@ -67,8 +81,8 @@ func TestValidateAndLoadStepFiles(t *testing.T) {
 
 		// Create files with a gap (missing step 2)
 		files := map[string]string{
-			"1.hcl": "step 1 content",
-			"3.hcl": "step 3 content",
+			"1.in.hcl": "step 1 content",
+			"3.in.hcl": "step 3 content",
 		}
 
 		for filename, content := range files {
@@ -78,6 +92,7 @@ func TestValidateAndLoadStepFiles(t *testing.T) {
 		}
 
 		config := &TestConfig[string, struct{}]{
+			InputExt:         ".hcl",
 			SuccessOutputExt: ".json",
 			ErrorOutputExt:   ".txt",
 		}
@@ -103,6 +118,7 @@ func TestValidateAndLoadStepFiles(t *testing.T) {
 		}
 
 		config := &TestConfig[string, struct{}]{
+			InputExt:         ".hcl",
 			SuccessOutputExt: ".json",
 			ErrorOutputExt:   ".txt",
 		}
@@ -123,11 +139,12 @@ func TestValidateAndLoadStepFiles(t *testing.T) {
 		}
 
 		// Create file with non-numeric name
-		if err := os.WriteFile(filepath.Join(stepDir, "invalid.hcl"), []byte("content"), 0644); err != nil {
+		if err := os.WriteFile(filepath.Join(stepDir, "invalid.in.hcl"), []byte("content"), 0644); err != nil {
 			t.Fatalf("failed to write file: %v", err)
 		}
 
 		config := &TestConfig[string, struct{}]{
+			InputExt:         ".hcl",
 			SuccessOutputExt: ".json",
 			ErrorOutputExt:   ".txt",
 		}
@@ -148,11 +165,12 @@ func TestValidateAndLoadStepFiles(t *testing.T) {
 		}
 
 		// Create file with step number 0
-		if err := os.WriteFile(filepath.Join(stepDir, "0.hcl"), []byte("content"), 0644); err != nil {
+		if err := os.WriteFile(filepath.Join(stepDir, "0.in.hcl"), []byte("content"), 0644); err != nil {
 			t.Fatalf("failed to write file: %v", err)
 		}
 
 		config := &TestConfig[string, struct{}]{
+			InputExt:         ".hcl",
 			SuccessOutputExt: ".json",
 			ErrorOutputExt:   ".txt",
 		}
@@ -187,8 +205,8 @@ func TestRunStepTests(t *testing.T) {
 
 	// Create step files
 	files := map[string]string{
-		"1.hcl": "first",
-		"2.hcl": "second",
+		"1.in.hcl": "first",
+		"2.in.hcl": "second",
 	}
 
 	for filename, content := range files {
@@ -244,3 +262,650 @@ func strings_Join(elems []string, sep string) string {
 	}
 	return result
 }
+
+func TestRunStepTestsParallel(t *testing.T) {
+	const cases = 6
+	fsys := fstest.MapFS{}
+	for i := 0; i < cases; i++ {
+		name := fmt.Sprintf("case_%d", i)
+		fsys[name+"/1.in.hcl"] = &fstest.MapFile{Data: []byte(name)}
+		fsys[name+"/1.out.json"] = &fstest.MapFile{Data: []byte(name)}
+	}
+
+	// -test.parallel also limits how many cases run at once; it defaults to
+	// the number of CPUs
+	want := int32(2)
+	if parallel, _ := strconv.Atoi(flag.Lookup("test.parallel").Value.String()); parallel < 2 {
+		want = int32(parallel)
+	}
+	var running, maxRunning atomic.Int32
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		Parallel:         true,
+		MaxParallelism:   2,
+		StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			// Give a second case the chance to start, so the limit is reached
+			for deadline := time.Now().Add(time.Second); running.Load() < want && time.Now().Before(deadline); {
+				time.Sleep(time.Millisecond)
+			}
+			time.Sleep(10 * time.Millisecond)
+			return string(stepFile.Data), nil
+		},
+	}
+
+	t.Run("suite", func(t *testing.T) {
+		config.RunTestsFS(t, fsys, ".")
+	})
+
+	if got := maxRunning.Load(); got != want {
+		t.Errorf("%d test cases ran at once, want %d", got, want)
+	}
+}
+
+func TestWriteGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1.out.json")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := writeGolden(path, []byte("new")); err != nil {
+				t.Errorf("writeGolden: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(data) != "new" {
+		t.Errorf("golden file contains %q, want %q", data, "new")
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the golden file to remain, got %d entries", len(entries))
+	}
+}
+
+func TestRunStepTestsSuiteFixture(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for _, name := range []string{"a", "b", "c"} {
+		fsys[name+"/1.in.hcl"] = &fstest.MapFile{Data: []byte(name)}
+		fsys[name+"/1.out.json"] = &fstest.MapFile{Data: []byte(name)}
+	}
+
+	type fixture struct {
+		ctx   context.Context
+		dirty bool
+	}
+	var setUps, resets, tearDowns int
+	config := &TestConfig[string, *fixture]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		SetUpSuite: func(ctx context.Context) (*fixture, error) {
+			setUps++
+			return &fixture{ctx: ctx}, nil
+		},
+		Reset: func(t *testing.T, f *fixture) error {
+			resets++
+			f.dirty = false
+			return nil
+		},
+		TearDownSuite: func(f *fixture) error {
+			tearDowns++
+			if f.ctx.Err() == nil {
+				return fmt.Errorf("the context of the suite is not done")
+			}
+			return nil
+		},
+		StepTestFunc: func(_ context.Context, f *fixture, stepFile StepFile) (string, error) {
+			if f.ctx.Err() != nil {
+				return "", fmt.Errorf("the context of the suite is done: %v", f.ctx.Err())
+			}
+			if f.dirty {
+				return "", fmt.Errorf("fixture was not reset")
+			}
+			f.dirty = true
+			return string(stepFile.Data), nil
+		},
+	}
+
+	t.Run("suite", func(t *testing.T) {
+		config.RunTestsFS(t, fsys, ".")
+	})
+
+	if setUps != 1 || resets != 2 || tearDowns != 1 {
+		t.Errorf("got %d set ups, %d resets and %d tear downs, want 1, 2 and 1", setUps, resets, tearDowns)
+	}
+}
+
+func TestRunStepTestsMidSequenceErrors(t *testing.T) {
+	fsys := contents(map[string]string{
+		"retry/1.in.hcl":   "create",
+		"retry/1.out.json": "created",
+		"retry/2.in.hcl":   "get",
+		"retry/2.out.txt":  "not found",
+		"retry/3.in.hcl":   "retry",
+		"retry/3.out.json": "found",
+	})
+
+	var ran []string
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		ErrorOutputExt:   ".txt",
+		SuccessOutputExt: ".json",
+		StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+			ran = append(ran, string(stepFile.Data))
+			switch string(stepFile.Data) {
+			case "create":
+				return "created", nil
+			case "get":
+				return "", fmt.Errorf("not found")
+			default:
+				return "found", nil
+			}
+		},
+		ErrorFunc: func(err error) []byte {
+			return []byte(err.Error())
+		},
+	}
+
+	config.RunTestsFS(t, fsys, ".")
+
+	if diff := cmp.Diff([]string{"create", "get", "retry"}, ran); diff != "" {
+		t.Errorf("steps run mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunStepTestsErrorCaseSuccessOutput(t *testing.T) {
+	// The final step of an error case is expected to fail unless it has a
+	// success output
+	fsys := contents(map[string]string{
+		"error_retry/1.in.hcl":   "get",
+		"error_retry/1.out.txt":  "not found",
+		"error_retry/2.in.hcl":   "retry",
+		"error_retry/2.out.json": "found",
+		"error_found/1.in.hcl":   "retry",
+		"error_found/1.out.json": "found",
+	})
+
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		ErrorOutputExt:   ".txt",
+		SuccessOutputExt: ".json",
+		ErrorPrefix:      "error_",
+		StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+			if string(stepFile.Data) == "get" {
+				return "", fmt.Errorf("not found")
+			}
+			return "found", nil
+		},
+		ErrorFunc: func(err error) []byte {
+			return []byte(err.Error())
+		},
+	}
+
+	config.RunTestsFS(t, fsys, ".")
+}
+
+func TestRunStep(t *testing.T) {
+	config := &TestConfig[string, struct{}]{
+		StepTestFunc: func(ctx context.Context, _ struct{}, stepFile StepFile) (string, error) {
+			switch string(stepFile.Data) {
+			case "hang":
+				select {}
+			case "panic":
+				panic("boom")
+			}
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+	}
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		outcome, returned := config.runStep(ctx, struct{}{}, StepFile{Step: 1, Data: []byte("wait")})
+		if !returned {
+			t.Fatal("runStep reported a step that honors its context as hung")
+		}
+		if !errors.Is(outcome.err, context.DeadlineExceeded) {
+			t.Errorf("step returned %v, want %v", outcome.err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("hung", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		if _, returned := config.runStep(ctx, struct{}{}, StepFile{Step: 1, Data: []byte("hang")}); returned {
+			t.Fatal("runStep did not report a step that ignores its context as hung")
+		}
+	})
+
+	t.Run("panicked", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+		defer cancel()
+		outcome, returned := config.runStep(ctx, struct{}{}, StepFile{Step: 1, Data: []byte("panic")})
+		if !returned {
+			t.Fatal("runStep reported a step that panicked as hung")
+		}
+		if outcome.panicked != "boom" {
+			t.Errorf("step panicked with %v, want boom", outcome.panicked)
+		}
+		if !strings.Contains(string(outcome.stack), "TestRunStep") {
+			t.Errorf("stack does not contain the step function:\n%s", outcome.stack)
+		}
+	})
+}
+
+func TestRunStepTestsRecursive(t *testing.T) {
+	fsys := fstest.MapFS{
+		"top/1.in.hcl":                   {Data: []byte("top")},
+		"top/1.out.json":                 {Data: []byte("top")},
+		"greet/unicode/1.in.hcl":         {Data: []byte("unicode")},
+		"greet/unicode/1.out.json":       {Data: []byte("unicode")},
+		"greet/nested/deeper/1.in.hcl":   {Data: []byte("deeper")},
+		"greet/nested/deeper/1.out.json": {Data: []byte("deeper")},
+		"greet/ascii.txtar":              {Data: []byte("-- 1.in.hcl --\nascii\n-- 1.out.json --\nascii\n")},
+	}
+
+	var mu sync.Mutex
+	var ran []string
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		Recursive:        true,
+		Parallel:         true,
+		StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, stepFile.FilePath)
+			return string(stepFile.Data), nil
+		},
+	}
+
+	t.Run("suite", func(t *testing.T) {
+		config.RunTestsFS(t, fsys, ".")
+	})
+
+	want := []string{
+		"greet/ascii.txtar/1.in.hcl",
+		"greet/nested/deeper/1.in.hcl",
+		"greet/unicode/1.in.hcl",
+		"top/1.in.hcl",
+	}
+	sort.Strings(ran)
+	if diff := cmp.Diff(want, ran); diff != "" {
+		t.Errorf("steps run mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunOneShotTestsRecursive(t *testing.T) {
+	fsys := fstest.MapFS{
+		"top.hcl":                      {Data: []byte("top")},
+		"top.out.json":                 {Data: []byte("top")},
+		"greet/unicode.hcl":            {Data: []byte("unicode")},
+		"greet/unicode.out.json":       {Data: []byte("unicode")},
+		"greet/deeper/error_x.hcl":     {Data: []byte("x")},
+		"greet/deeper/error_x.out.txt": {Data: []byte("bad x")},
+	}
+
+	var ran []string
+	config := &TestConfig[string, struct{}]{
+		InputExt:         ".hcl",
+		SuccessOutputExt: ".json",
+		ErrorOutputExt:   ".txt",
+		ErrorFunc:        func(err error) []byte { return []byte(err.Error()) },
+		TestOneShotFunc: func(_ struct{}, filePath string, data []byte) (string, error) {
+			ran = append(ran, filePath)
+			if string(data) == "x" {
+				return "", fmt.Errorf("bad x")
+			}
+			return string(data), nil
+		},
+	}
+
+	for _, recursive := range []bool{false, true} {
+		ran = nil
+		config.Recursive = recursive
+		t.Run(fmt.Sprintf("recursive=%v", recursive), func(t *testing.T) {
+			config.RunTestsFS(t, fsys, ".")
+		})
+
+		want := []string{"top.hcl"}
+		if recursive {
+			want = []string{"top.hcl", "greet/unicode.hcl", "greet/deeper/error_x.hcl"}
+		}
+		if diff := cmp.Diff(want, ran); diff != "" {
+			t.Errorf("recursive=%v: cases run mismatch (-want +got):\n%s", recursive, diff)
+		}
+	}
+}
+
+// unexpectedErrorDirEnv names the testdata directory of the test cases run by
+// TestRunStepTestsUnexpectedError in a subprocess, since the cases fail.
+const unexpectedErrorDirEnv = "GOLDENTEST_UNEXPECTED_ERROR_DIR"
+
+func TestRunStepTestsUnexpectedError(t *testing.T) {
+	if dir := os.Getenv(unexpectedErrorDirEnv); dir != "" {
+		config := &TestConfig[string, struct{}]{
+			InputExt:         ".hcl",
+			ErrorOutputExt:   ".txt",
+			SuccessOutputExt: ".json",
+			StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+				if string(stepFile.Data) == "get" {
+					return "", fmt.Errorf("not found")
+				}
+				return string(stepFile.Data), nil
+			},
+			ErrorFunc: func(err error) []byte {
+				return []byte(err.Error())
+			},
+		}
+		config.RunTests(t, dir)
+		return
+	}
+
+	for name, args := range map[string][]string{"check": nil, "update": {"-update"}} {
+		t.Run(name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			stepDir := filepath.Join(tempDir, "lookup")
+			if err := os.MkdirAll(stepDir, 0755); err != nil {
+				t.Fatalf("failed to create step dir: %v", err)
+			}
+			// Step 2 has no outputs, so it is expected to succeed
+			for filename, content := range map[string]string{
+				"1.in.hcl":   "create",
+				"1.out.json": "create",
+				"2.in.hcl":   "get",
+				"3.in.hcl":   "delete",
+			} {
+				if err := os.WriteFile(filepath.Join(stepDir, filename), []byte(content), 0644); err != nil {
+					t.Fatalf("failed to write file %s: %v", filename, err)
+				}
+			}
+
+			cmd := exec.Command(os.Args[0], append([]string{"-test.run=^TestRunStepTestsUnexpectedError$"}, args...)...)
+			cmd.Env = append(os.Environ(), unexpectedErrorDirEnv+"="+tempDir)
+			out, err := cmd.CombinedOutput()
+			if err == nil {
+				t.Fatalf("test cases passed, want them to fail on the error of step 2:\n%s", out)
+			}
+			if !strings.Contains(string(out), "unexpected error for step 2: not found") {
+				t.Errorf("output does not report the unexpected error of step 2:\n%s", out)
+			}
+			// The error is not recorded, and the steps after it do not run
+			for _, name := range []string{"2.out.txt", "2.out.json", "3.out.json"} {
+				if _, err := os.Stat(filepath.Join(stepDir, name)); err == nil {
+					t.Errorf("%s was written, want it left missing", name)
+				}
+			}
+		})
+	}
+}
+
+// errorCaseDirEnv names the testdata directory of the test cases run by
+// TestRunStepTestsErrorCaseWithoutOutput in a subprocess, since a case fails.
+const errorCaseDirEnv = "GOLDENTEST_ERROR_CASE_DIR"
+
+func TestRunStepTestsErrorCaseWithoutOutput(t *testing.T) {
+	if dir := os.Getenv(errorCaseDirEnv); dir != "" {
+		config := &TestConfig[string, struct{}]{
+			InputExt:         ".hcl",
+			ErrorOutputExt:   ".txt",
+			SuccessOutputExt: ".json",
+			ErrorPrefix:      "error_",
+			StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+				if string(stepFile.Data) == "get" {
+					return "", fmt.Errorf("not found")
+				}
+				return string(stepFile.Data), nil
+			},
+			ErrorFunc: func(err error) []byte {
+				return []byte(err.Error())
+			},
+		}
+		config.RunTests(t, dir)
+		return
+	}
+
+	tempDir := t.TempDir()
+	// The final steps have no outputs, so they are expected to fail
+	for filename, content := range map[string]string{
+		"error_fails/1.in.hcl":      "create",
+		"error_fails/1.out.json":    "create",
+		"error_fails/2.in.hcl":      "get",
+		"error_succeeds/1.in.hcl":   "create",
+		"error_succeeds/1.out.json": "create",
+		"error_succeeds/2.in.hcl":   "delete",
+	} {
+		path := filepath.Join(tempDir, filename)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create step dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file %s: %v", filename, err)
+		}
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.v", "-test.run=^TestRunStepTestsErrorCaseWithoutOutput$", "-update")
+	cmd.Env = append(os.Environ(), errorCaseDirEnv+"="+tempDir)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("test cases passed, want the final step that succeeds to fail:\n%s", out)
+	}
+	for _, line := range []string{
+		"--- PASS: TestRunStepTestsErrorCaseWithoutOutput/error_fails",
+		"--- FAIL: TestRunStepTestsErrorCaseWithoutOutput/error_succeeds/step_2",
+		"expected step 2 to fail as the final step of an error case, but it succeeded",
+	} {
+		if !strings.Contains(string(out), line) {
+			t.Errorf("output does not contain %q:\n%s", line, out)
+		}
+	}
+	// The error is recorded, but the unexpected success is not
+	data, err := os.ReadFile(filepath.Join(tempDir, "error_fails", "2.out.txt"))
+	if err != nil || string(data) != "not found" {
+		t.Errorf("error_fails/2.out.txt = %q, %v, want the recorded error", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "error_succeeds", "2.out.json")); err == nil {
+		t.Error("error_succeeds/2.out.json was written, want it left missing")
+	}
+}
+
+// panicDirEnv names the testdata directory of the test cases run by
+// TestRunStepTestsPanic in a subprocess, since a case fails.
+const panicDirEnv = "GOLDENTEST_PANIC_DIR"
+
+func TestRunStepTestsPanic(t *testing.T) {
+	if dir := os.Getenv(panicDirEnv); dir != "" {
+		config := &TestConfig[string, struct{}]{
+			InputExt:         ".hcl",
+			SuccessOutputExt: ".json",
+			SetUp: func(*testing.T) (struct{}, error) {
+				return struct{}{}, nil
+			},
+			TearDown: func(*testing.T, struct{}) error {
+				fmt.Println("tore down")
+				return nil
+			},
+			StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+				if string(stepFile.Data) == "panic" {
+					panic("boom")
+				}
+				return string(stepFile.Data), nil
+			},
+		}
+		config.RunTests(t, dir)
+		return
+	}
+
+	tempDir := t.TempDir()
+	for filename, content := range map[string]string{
+		"a/1.in.hcl":   "panic",
+		"b/1.in.hcl":   "get",
+		"b/1.out.json": "get",
+	} {
+		path := filepath.Join(tempDir, filename)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create step dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file %s: %v", filename, err)
+		}
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.v", "-test.run=^TestRunStepTestsPanic$")
+	cmd.Env = append(os.Environ(), panicDirEnv+"="+tempDir)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("test cases passed, want the panicking step to fail:\n%s", out)
+	}
+	// The panic fails its step with its stack, and the test binary goes on
+	// to tear the fixture down and run the next test case
+	for _, line := range []string{
+		"step 1 (1.in.hcl) panicked: boom",
+		"goroutine ",
+		"--- FAIL: TestRunStepTestsPanic/a/step_1",
+		"--- PASS: TestRunStepTestsPanic/b",
+	} {
+		if !strings.Contains(string(out), line) {
+			t.Errorf("output does not contain %q:\n%s", line, out)
+		}
+	}
+	if got := strings.Count(string(out), "tore down"); got != 2 {
+		t.Errorf("fixtures were torn down %d times, want 2:\n%s", got, out)
+	}
+}
+
+// selectionDirEnv names the testdata directory of the test cases run by
+// TestRunStepTestsSelection in a subprocess, which selects steps with -run.
+const selectionDirEnv = "GOLDENTEST_SELECTION_DIR"
+
+func TestRunStepTestsSelection(t *testing.T) {
+	if dir := os.Getenv(selectionDirEnv); dir != "" {
+		config := &TestConfig[string, struct{}]{
+			InputExt:         ".hcl",
+			SuccessOutputExt: ".json",
+			StepTestFunc: func(_ context.Context, _ struct{}, stepFile StepFile) (string, error) {
+				fmt.Printf("ran %s\n", stepFile.Data)
+				return string(stepFile.Data), nil
+			},
+		}
+		config.RunTests(t, dir)
+		return
+	}
+
+	tempDir := t.TempDir()
+	stepDir := filepath.Join(tempDir, "sequence")
+	if err := os.MkdirAll(stepDir, 0755); err != nil {
+		t.Fatalf("failed to create step dir: %v", err)
+	}
+	// Step 1 does not match its output, which only fails the test if it is checked
+	for filename, content := range map[string]string{
+		"1.in.hcl":   "first",
+		"1.out.json": "wrong",
+		"2.in.hcl":   "second",
+		"2.out.json": "second",
+		"3.in.hcl":   "third",
+		"3.out.json": "wrong",
+	} {
+		if err := os.WriteFile(filepath.Join(stepDir, filename), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file %s: %v", filename, err)
+		}
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.v", "-test.run=^TestRunStepTestsSelection$/sequence/step_2")
+	cmd.Env = append(os.Environ(), selectionDirEnv+"="+tempDir)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("selected step failed: %v\n%s", err, out)
+	}
+	// Step 1 runs unchecked since step 2 depends on it, and step 3 does not run
+	for _, line := range []string{"ran first", "ran second", "--- PASS: TestRunStepTestsSelection/sequence/step_2"} {
+		if !strings.Contains(string(out), line) {
+			t.Errorf("output does not contain %q:\n%s", line, out)
+		}
+	}
+	for _, line := range []string{"ran third", "step_1", "step_3"} {
+		if strings.Contains(string(out), line) {
+			t.Errorf("output contains %q, want only step 2 to be checked and no step after it to run:\n%s", line, out)
+		}
+	}
+}
+
+// hungStepDirEnv names the testdata directory of the test cases run by
+// TestRunStepTestsHungStepDiscardsFixture in a subprocess, since a case fails.
+const hungStepDirEnv = "GOLDENTEST_HUNG_STEP_DIR"
+
+func TestRunStepTestsHungStepDiscardsFixture(t *testing.T) {
+	if dir := os.Getenv(hungStepDirEnv); dir != "" {
+		setUps := 0
+		config := &TestConfig[string, int]{
+			InputExt:         ".hcl",
+			SuccessOutputExt: ".json",
+			StepTimeout:      10 * time.Millisecond,
+			SetUpSuite: func(context.Context) (int, error) {
+				setUps++
+				return setUps, nil
+			},
+			StepTestFunc: func(_ context.Context, fixture int, stepFile StepFile) (string, error) {
+				if string(stepFile.Data) == "hang" {
+					// Ignores its context, and still holds the fixture
+					select {}
+				}
+				fmt.Printf("used fixture %d\n", fixture)
+				return string(stepFile.Data), nil
+			},
+		}
+		config.RunTests(t, dir)
+		return
+	}
+
+	tempDir := t.TempDir()
+	for filename, content := range map[string]string{
+		"a/1.in.hcl":   "hang",
+		"b/1.in.hcl":   "get",
+		"b/1.out.json": "get",
+	} {
+		path := filepath.Join(tempDir, filename)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create step dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file %s: %v", filename, err)
+		}
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRunStepTestsHungStepDiscardsFixture$")
+	cmd.Env = append(os.Environ(), hungStepDirEnv+"="+tempDir)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("test cases passed, want the hung step to fail:\n%s", out)
+	}
+	if !strings.Contains(string(out), "step 1 (1.in.hcl) hung") {
+		t.Errorf("output does not report the hung step:\n%s", out)
+	}
+	// The fixture of the hung step is not handed to the next test case
+	if !strings.Contains(string(out), "used fixture 2") {
+		t.Errorf("output does not show the next test case using a new fixture:\n%s", out)
+	}
+}
@@ -0,0 +1,275 @@
+package goldentest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// UpdateMode says which golden files -update writes.
+type UpdateMode string
+
+const (
+	// UpdateNone compares results against golden files without changing them.
+	UpdateNone UpdateMode = ""
+	// UpdateAll rewrites golden files that do not match and creates missing ones.
+	UpdateAll UpdateMode = "all"
+	// UpdateMissing only creates golden files that do not exist yet.
+	UpdateMissing UpdateMode = "missing"
+	// UpdatePending leaves golden files alone and writes the result of every
+	// test case that does not match as a pending golden file, which the test
+	// still fails on until it is accepted with the review command.
+	UpdatePending UpdateMode = "pending"
+)
+
+// PendingExt is appended to the name of a golden file to name its pending
+// version, written by -update=pending. The pending versions of the sections of
+// a txtar archive are stored in an archive named like it with PendingExt
+// appended.
+const PendingExt = ".new"
+
+// updateDryRun is the -update term that enables UpdateFlag.DryRun.
+const updateDryRun = "dry-run"
+
+// UpdateFlag is the value of the -update flag. It is a boolean flag, so
+// -update on its own means -update=all. Otherwise its value is a comma
+// separated list of terms:
+//
+//   - all, missing or pending: the Mode (all if only other terms are given)
+//   - dry-run: list the golden files that would change without writing them
+//   - anything else: a glob pattern (see path.Match) of test cases to update
+//
+// For example, -update=multi_*,dry-run lists the golden files of the test
+// cases whose name starts with multi_ that -update would change.
+type UpdateFlag struct {
+	Mode UpdateMode
+	// Cases are glob patterns matched against the test case names. If empty,
+	// every test case is updated.
+	Cases []string
+	// DryRun logs the golden files that would be written or removed instead
+	// of changing them. Run the tests with -v to see the list.
+	DryRun bool
+}
+
+// String implements flag.Value.
+func (u *UpdateFlag) String() string {
+	if u == nil || u.Mode == UpdateNone {
+		return ""
+	}
+	terms := append([]string{string(u.Mode)}, u.Cases...)
+	if u.DryRun {
+		terms = append(terms, updateDryRun)
+	}
+	return strings.Join(terms, ",")
+}
+
+// Set implements flag.Value.
+func (u *UpdateFlag) Set(value string) error {
+	var parsed UpdateFlag
+	for _, term := range strings.Split(value, ",") {
+		switch term {
+		case "true", string(UpdateAll):
+			parsed.Mode = UpdateAll
+		case "false", string(UpdateNone):
+			*u = UpdateFlag{}
+			return nil
+		case string(UpdateMissing):
+			parsed.Mode = UpdateMissing
+		case string(UpdatePending):
+			parsed.Mode = UpdatePending
+		case updateDryRun:
+			parsed.DryRun = true
+		default:
+			if _, err := path.Match(term, ""); err != nil {
+				return fmt.Errorf("invalid test case pattern %q: %w", term, err)
+			}
+			parsed.Cases = append(parsed.Cases, term)
+		}
+	}
+	if parsed.Mode == UpdateNone {
+		parsed.Mode = UpdateAll
+	}
+	*u = parsed
+	return nil
+}
+
+// IsBoolFlag makes -update without a value mean -update=true.
+func (u *UpdateFlag) IsBoolFlag() bool {
+	return true
+}
+
+// matches reports whether the test named name is selected by Cases. Patterns
+// are matched against each element of the name below the top-level test, so
+// they select test cases by name wherever they are nested.
+func (u *UpdateFlag) matches(name string) bool {
+	if len(u.Cases) == 0 {
+		return true
+	}
+	elems := strings.Split(name, "/")
+	for _, elem := range elems[min(1, len(elems)-1):] {
+		for _, pattern := range u.Cases {
+			if ok, _ := path.Match(pattern, elem); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rewrites reports whether the golden files of the test named name that exist
+// but do not match are rewritten, or removed if they are stale.
+func (u *UpdateFlag) rewrites(name string) bool {
+	return u.Mode == UpdateAll && u.matches(name)
+}
+
+// creates reports whether the golden files of the test named name that do not
+// exist are created.
+func (u *UpdateFlag) creates(name string) bool {
+	return (u.Mode == UpdateAll || u.Mode == UpdateMissing) && u.matches(name)
+}
+
+// pending reports whether the results of the test named name that do not
+// match their golden files are written as pending golden files.
+func (u *UpdateFlag) pending(name string) bool {
+	return u.Mode == UpdatePending && u.matches(name)
+}
+
+// updateGolden writes data to the named golden file, or only logs that it
+// would in a dry run. It returns false if writing failed.
+func updateGolden(t *testing.T, files caseFiles, name string, data []byte) bool {
+	t.Helper()
+	path := files.path(name)
+	if Update.DryRun {
+		t.Logf("would update golden file %s", path)
+		return true
+	}
+	if err := files.write(name, data); err != nil {
+		t.Errorf("failed to update golden file %s: %v", path, err)
+		return false
+	}
+	reportUpdated(t)
+	return true
+}
+
+// removeGolden removes the named golden file, which is stale, or only logs
+// that it would in a dry run.
+func removeGolden(t *testing.T, files caseFiles, name string) {
+	t.Helper()
+	path := files.path(name)
+	if Update.DryRun {
+		t.Logf("would remove stale golden file %s", path)
+		return
+	}
+	if err := files.remove(name); err != nil {
+		t.Errorf("failed to remove stale golden file %s: %v", path, err)
+		return
+	}
+	reportUpdated(t)
+	t.Logf("removed stale golden file %s", path)
+}
+
+// writePending writes the output of format as the pending version of the
+// named golden file, whose test case fails, if -update=pending. A dry run only
+// logs that it would.
+func writePending(t *testing.T, files caseFiles, name string, format func() ([]byte, error)) {
+	t.Helper()
+	if !Update.pending(t.Name()) {
+		return
+	}
+	path := files.path(name)
+	if Update.DryRun {
+		t.Logf("would write a pending version of golden file %s", path)
+		return
+	}
+	data, err := format()
+	if err != nil {
+		t.Errorf("failed to format result for %s: %v", path, err)
+		return
+	}
+	if err := files.writePending(name, data); err != nil {
+		t.Errorf("failed to write pending version of golden file %s: %v", path, err)
+		return
+	}
+	t.Logf("wrote a pending version of golden file %s; review it with:\n\tgo run ./internal/goldentest/cmd/review", path)
+}
+
+// readGolden reads the named golden file. If it does not exist, readGolden
+// creates it from the output of format when the update mode allows it, or fails
+// t with the command that creates it otherwise. It returns false if the caller
+// should skip comparing against the golden file.
+func readGolden(t *testing.T, files caseFiles, name string, format func() ([]byte, error)) ([]byte, bool) {
+	t.Helper()
+	path := files.path(name)
+	reportGolden(t, path)
+	if Update.pending(t.Name()) && !Update.DryRun {
+		// A pending version from an earlier run is out of date; it is
+		// written again if the result still does not match.
+		if err := files.removePending(name); err != nil {
+			t.Errorf("failed to remove pending version of golden file %s: %v", path, err)
+		}
+	}
+	data, err := files.read(name)
+	if err == nil {
+		return data, true
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("failed to read golden file %s: %v", path, err)
+		return nil, false
+	}
+
+	if !Update.creates(t.Name()) {
+		writePending(t, files, name, format)
+		t.Errorf("golden file %s is missing; create it with:\n\tgo test -run '%s' -update=missing .", path, runPattern(t.Name()))
+		return nil, false
+	}
+	actual, err := format()
+	if err != nil {
+		t.Errorf("failed to format result for %s: %v", path, err)
+		return nil, false
+	}
+	if updateGolden(t, files, name, actual) && !Update.DryRun {
+		t.Logf("created golden file %s", path)
+	}
+	return nil, false
+}
+
+// checkFormatted fails t if -golden-check is set and golden, the content of
+// the named golden file that matches result, is not exactly what the
+// Formatter produces for result. With -update, the file is reformatted.
+func (config *TestConfig[T, F]) checkFormatted(t *testing.T, files caseFiles, name string, golden []byte, result T) {
+	t.Helper()
+	if !*GoldenCheck {
+		return
+	}
+	path := files.path(name)
+	formatted, err := config.Formatter(result)
+	if err != nil {
+		t.Errorf("failed to format result for %s: %v", path, err)
+		return
+	}
+	if bytes.Equal(golden, formatted) {
+		return
+	}
+	diff := unifiedDiff(path, golden, formatted, colorDiffs)
+	reportDiff(t, diff)
+	if Update.rewrites(t.Name()) {
+		updateGolden(t, files, name, formatted)
+		return
+	}
+	writePending(t, files, name, func() ([]byte, error) { return formatted, nil })
+	t.Errorf("golden file %s matches but is not formatted; run with -update to reformat it:\n%s", path, diff)
+}
+
+// runPattern returns a -run pattern that matches exactly the test named name.
+func runPattern(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+	return strings.Join(parts, "/")
+}
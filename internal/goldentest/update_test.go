@@ -0,0 +1,96 @@
+package goldentest
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUpdateFlag(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want UpdateFlag
+	}{
+		{args: nil, want: UpdateFlag{}},
+		{args: []string{"-update"}, want: UpdateFlag{Mode: UpdateAll}},
+		{args: []string{"-update=true"}, want: UpdateFlag{Mode: UpdateAll}},
+		{args: []string{"-update=all"}, want: UpdateFlag{Mode: UpdateAll}},
+		{args: []string{"-update=missing"}, want: UpdateFlag{Mode: UpdateMissing}},
+		{args: []string{"-update=pending,multi_*"}, want: UpdateFlag{Mode: UpdatePending, Cases: []string{"multi_*"}}},
+		{args: []string{"-update=false"}, want: UpdateFlag{}},
+		{args: []string{"-update=multi_*"}, want: UpdateFlag{Mode: UpdateAll, Cases: []string{"multi_*"}}},
+		{args: []string{"-update=dry-run"}, want: UpdateFlag{Mode: UpdateAll, DryRun: true}},
+		{args: []string{"-update=missing,a*,b*,dry-run"}, want: UpdateFlag{Mode: UpdateMissing, Cases: []string{"a*", "b*"}, DryRun: true}},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		var update UpdateFlag
+		fs.Var(&update, "update", "")
+		if err := fs.Parse(tt.args); err != nil {
+			t.Errorf("Parse(%q): %v", tt.args, err)
+			continue
+		}
+		if diff := cmp.Diff(tt.want, update); diff != "" {
+			t.Errorf("Parse(%q) mismatch (-want +got):\n%s", tt.args, diff)
+		}
+	}
+
+	var update UpdateFlag
+	if err := update.Set("[unclosed"); err == nil {
+		t.Error("expected an error for a malformed pattern, got none")
+	}
+}
+
+func TestUpdateFlagSelectsCases(t *testing.T) {
+	update := UpdateFlag{Mode: UpdateAll, Cases: []string{"multi_*"}}
+	for name, want := range map[string]bool{
+		"TestGolden/multi_step":         true,
+		"TestGolden/multi_step/step_1":  true,
+		"TestGolden/simple_greet":       false,
+		"TestGolden/simple/multi_steps": true,
+		"multi_test":                    true,
+	} {
+		if got := update.rewrites(name); got != want {
+			t.Errorf("rewrites(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if (&UpdateFlag{Mode: UpdateMissing}).rewrites("TestGolden/case") {
+		t.Error("-update=missing rewrites existing golden files")
+	}
+	if pending := (&UpdateFlag{Mode: UpdatePending}); pending.rewrites("TestGolden/case") || pending.creates("TestGolden/case") {
+		t.Error("-update=pending writes golden files")
+	}
+}
+
+func TestStableProtoText(t *testing.T) {
+	in := "rpc:  {\n  greet_response:  {\n    message:  \"a:  b\"\n  }\n}\n"
+	want := "rpc: {\n  greet_response: {\n    message: \"a:  b\"\n  }\n}\n"
+	if got := string(stableProtoText([]byte(in))); got != want {
+		t.Errorf("stableProtoText = %q, want %q", got, want)
+	}
+}
+
+func TestRunPattern(t *testing.T) {
+	if got, want := runPattern("TestGolden/error_case.hcl"), `^TestGolden$/^error_case\.hcl$`; got != want {
+		t.Errorf("runPattern = %q, want %q", got, want)
+	}
+}
+
+func TestReadGoldenCreatesMissing(t *testing.T) {
+	defer func(update UpdateFlag) { *Update = update }(*Update)
+	*Update = UpdateFlag{Mode: UpdateMissing}
+
+	fsys := memFS{}
+	files := dirFiles{fsys: fsys, dir: "."}
+	if _, ok := readGolden(t, files, "1.out.json", func() ([]byte, error) { return []byte("created"), nil }); ok {
+		t.Error("readGolden returned true for a missing golden file")
+	}
+	if got := fsys["1.out.json"]; got == nil || string(got.Data) != "created" {
+		t.Errorf("golden file is %v, want %q", got, "created")
+	}
+
+	data, ok := readGolden(t, files, "1.out.json", func() ([]byte, error) { return []byte("rewritten"), nil })
+	if !ok || string(data) != "created" {
+		t.Errorf("readGolden = %q, %v, want %q, true", data, ok, "created")
+	}
+}
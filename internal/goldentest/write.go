@@ -0,0 +1,38 @@
+package goldentest
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// goldenWriteMu serializes golden file writes so that parallel test cases
+// updating files in the same directory never interleave.
+var goldenWriteMu sync.Mutex
+
+// writeGolden atomically replaces the golden file at path with data. The data
+// is written to a temporary file in the same directory and renamed into place,
+// so a reader never observes a partially written golden file.
+func writeGolden(path string, data []byte) error {
+	goldenWriteMu.Lock()
+	defer goldenWriteMu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"context"
+	"strings"
+
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	api "github.com/achew22/toy-project/api/v1"
+	"github.com/achew22/toy-project/internal/config"
+)
+
+// AdminService implements the AdminServer interface
+type AdminService struct {
+	api.UnimplementedAdminServer
+
+	// Config is the configuration the server is running with, or nil if it
+	// was started without a configuration file.
+	Config *config.Effective
+}
+
+// GetEffectiveConfig implements the GetEffectiveConfig method of the AdminServer interface
+func (s *AdminService) GetEffectiveConfig(ctx context.Context, req *api.GetEffectiveConfigRequest) (*api.GetEffectiveConfigResponse, error) {
+	if s.Config == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the server was started without a configuration file")
+	}
+
+	var rendered strings.Builder
+	switch req.GetFormat() {
+	case api.ConfigFormat_CONFIG_FORMAT_UNSPECIFIED, api.ConfigFormat_CONFIG_FORMAT_HCL:
+		if err := s.Config.WriteHCL(&rendered); err != nil {
+			return nil, status.Errorf(codes.Internal, "rendering config: %v", err)
+		}
+	case api.ConfigFormat_CONFIG_FORMAT_JSON:
+		if err := s.Config.WriteJSON(&rendered); err != nil {
+			return nil, status.Errorf(codes.Internal, "rendering config: %v", err)
+		}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown format %v", req.GetFormat())
+	}
+
+	resp := &api.GetEffectiveConfigResponse{Rendered: rendered.String()}
+	for _, v := range s.Config.Values() {
+		value := v.Redacted()
+		jsonValue, err := ctyjson.Marshal(value, value.Type())
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "encoding %s: %v", strings.Join(v.Path, "."), err)
+		}
+		resp.Values = append(resp.Values, &api.ConfigValue{
+			Path:      strings.Join(v.Path, "."),
+			JsonValue: string(jsonValue),
+			Source:    v.Provenance(),
+			Sensitive: v.Sensitive,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,60 @@
+package admin_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	api "github.com/achew22/toy-project/api/v1"
+	"github.com/achew22/toy-project/internal/config"
+	"github.com/achew22/toy-project/internal/server/admin"
+	"github.com/achew22/toy-project/internal/server/servertest"
+)
+
+func TestAdminService_Golden(t *testing.T) {
+	servertest.RunGoldenStepTests(t)
+}
+
+func TestAdminService_GetEffectiveConfig(t *testing.T) {
+	effective, diags := config.Evaluate("server.hcl", []byte(`server {
+  listening_address = "127.0.0.1:8080"
+}
+`))
+	if diags.HasErrors() {
+		t.Fatalf("Evaluate: %v", diags)
+	}
+
+	service := &admin.AdminService{Config: effective}
+	resp, err := service.GetEffectiveConfig(context.Background(), &api.GetEffectiveConfigRequest{})
+	if err != nil {
+		t.Fatalf("GetEffectiveConfig: %v", err)
+	}
+
+	want := &api.GetEffectiveConfigResponse{
+		Rendered: "server {\n" +
+			"  listening_address = \"127.0.0.1:8080\" # server.hcl:2,23-39\n" +
+			"  metrics_address = \"\" # unset\n" +
+			"  tls_cert_file = \"\" # unset\n" +
+			"  tls_key_file = \"\" # unset\n" +
+			"  client_ca_file = \"\" # unset\n" +
+			"  require_client_cert = false # unset\n" +
+			"}\n",
+		Values: []*api.ConfigValue{
+			{
+				Path:      "server.listening_address",
+				JsonValue: `"127.0.0.1:8080"`,
+				Source:    "server.hcl:2,23-39",
+			},
+			{Path: "server.metrics_address", JsonValue: `""`, Source: "unset"},
+			{Path: "server.tls_cert_file", JsonValue: `""`, Source: "unset"},
+			{Path: "server.tls_key_file", JsonValue: `""`, Source: "unset"},
+			{Path: "server.client_ca_file", JsonValue: `""`, Source: "unset"},
+			{Path: "server.require_client_cert", JsonValue: "false", Source: "unset"},
+		},
+	}
+	if diff := cmp.Diff(want, resp, protocmp.Transform()); diff != "" {
+		t.Errorf("GetEffectiveConfig mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -3,9 +3,34 @@ package helloworld_test
 import (
 	"testing"
 
+	"github.com/achew22/toy-project/internal/goldentest"
+	"github.com/achew22/toy-project/internal/server/helloworld"
 	"github.com/achew22/toy-project/internal/server/servertest"
+
+	pb "github.com/achew22/toy-project/internal/server/servertest/proto/v1"
 )
 
 func TestHelloWorldService_Golden(t *testing.T) {
 	servertest.RunGoldenStepTests(t)
 }
+
+func BenchmarkHelloWorldService_Golden(b *testing.B) {
+	servertest.RunGoldenStepBenchmarks(b)
+}
+
+func FuzzHelloWorldService_Greet(f *testing.F) {
+	service := &helloworld.HelloWorldService{}
+	goldentest.FuzzProto(f, "testdata", ".in.textpb", func(t *testing.T, in *pb.TestStepIn) {
+		req := in.GetRpc().GetGreetRequest()
+		if req == nil {
+			t.Skip("not a Greet request")
+		}
+		resp, err := service.Greet(t.Context(), req)
+		if err != nil {
+			t.Fatalf("Greet(%v): %v", req, err)
+		}
+		if want := "Hello, " + req.GetName(); resp.GetMessage() != want {
+			t.Errorf("Greet(%v) = %q, want %q", req, resp.GetMessage(), want)
+		}
+	})
+}
@@ -6,6 +6,8 @@ import (
 	"net"
 
 	api "github.com/achew22/toy-project/api/v1"
+	"github.com/achew22/toy-project/internal/config"
+	"github.com/achew22/toy-project/internal/server/admin"
 	"github.com/achew22/toy-project/internal/server/helloworld"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
@@ -13,17 +15,34 @@ import (
 
 type Server struct {
 	grpcServer *grpc.Server
+	config     *config.Effective
 }
 
-func NewServer() *Server {
+// Option configures a Server created by NewServer.
+type Option func(*Server)
+
+// WithEffectiveConfig sets the configuration the server reports through the
+// admin service.
+func WithEffectiveConfig(effective *config.Effective) Option {
+	return func(s *Server) {
+		s.config = effective
+	}
+}
+
+func NewServer(opts ...Option) *Server {
 	s := &Server{
 		grpcServer: grpc.NewServer(),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
 	s.register()
 	return s
 }
 
 func (s *Server) register() {
+	adminService := &admin.AdminService{Config: s.config}
+	api.RegisterAdminServer(s.grpcServer, adminService)
 	helloworldService := &helloworld.HelloWorldService{}
 	api.RegisterHelloWorldServer(s.grpcServer, helloworldService)
 	reflection.Register(s.grpcServer)
@@ -15,17 +15,34 @@ import (
 //go:generate make protos
 
 type Client struct {
+	adminClient      api.AdminClient
 	helloworldClient api.HelloWorldClient
 }
 
 func NewClient(conn grpc.ClientConnInterface) *Client {
 	return &Client{
+		adminClient:      api.NewAdminClient(conn),
 		helloworldClient: api.NewHelloWorldClient(conn),
 	}
 }
 
 func (c *Client) Execute(ctx context.Context, req *Request) (*Response, error) {
 	switch r := req.Request.(type) {
+	case *Request_GeteffectiveconfigRequest:
+		resp, err := c.adminClient.GetEffectiveConfig(ctx, r.GeteffectiveconfigRequest)
+		if err != nil {
+			st, _ := status.FromError(err)
+			return &Response{
+				Response: &Response_Status{
+					Status: st.Proto(),
+				},
+			}, nil
+		}
+		return &Response{
+			Response: &Response_GeteffectiveconfigResponse{
+				GeteffectiveconfigResponse: resp,
+			},
+		}, nil
 	case *Request_GreetRequest:
 		resp, err := c.helloworldClient.Greet(ctx, r.GreetRequest)
 		if err != nil {
@@ -28,8 +28,8 @@ type Request struct {
 	unknownFields protoimpl.UnknownFields
 
 	// Types that are assignable to Request:
-	//
 	//	*Request_GreetRequest
+	//	*Request_GeteffectiveconfigRequest
 	Request isRequest_Request `protobuf_oneof:"request"`
 }
 
@@ -79,6 +79,13 @@ func (x *Request) GetGreetRequest() *v1.GreetRequest {
 	return nil
 }
 
+func (x *Request) GetGeteffectiveconfigRequest() *v1.GetEffectiveConfigRequest {
+	if x, ok := x.GetRequest().(*Request_GeteffectiveconfigRequest); ok {
+		return x.GeteffectiveconfigRequest
+	}
+	return nil
+}
+
 type isRequest_Request interface {
 	isRequest_Request()
 }
@@ -87,17 +94,23 @@ type Request_GreetRequest struct {
 	GreetRequest *v1.GreetRequest `protobuf:"bytes,1,opt,name=greet_request,json=greetRequest,proto3,oneof"`
 }
 
+type Request_GeteffectiveconfigRequest struct {
+	GeteffectiveconfigRequest *v1.GetEffectiveConfigRequest `protobuf:"bytes,2,opt,name=geteffectiveconfig_request,json=geteffectiveconfigRequest,proto3,oneof"`
+}
+
 func (*Request_GreetRequest) isRequest_Request() {}
 
+func (*Request_GeteffectiveconfigRequest) isRequest_Request() {}
+
 type Response struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
 	// Types that are assignable to Response:
-	//
 	//	*Response_Status
 	//	*Response_GreetResponse
+	//	*Response_GeteffectiveconfigResponse
 	Response isResponse_Response `protobuf_oneof:"response"`
 }
 
@@ -154,6 +167,13 @@ func (x *Response) GetGreetResponse() *v1.GreetResponse {
 	return nil
 }
 
+func (x *Response) GetGeteffectiveconfigResponse() *v1.GetEffectiveConfigResponse {
+	if x, ok := x.GetResponse().(*Response_GeteffectiveconfigResponse); ok {
+		return x.GeteffectiveconfigResponse
+	}
+	return nil
+}
+
 type isResponse_Response interface {
 	isResponse_Response()
 }
@@ -166,10 +186,16 @@ type Response_GreetResponse struct {
 	GreetResponse *v1.GreetResponse `protobuf:"bytes,2,opt,name=greet_response,json=greetResponse,proto3,oneof"`
 }
 
+type Response_GeteffectiveconfigResponse struct {
+	GeteffectiveconfigResponse *v1.GetEffectiveConfigResponse `protobuf:"bytes,3,opt,name=geteffectiveconfig_response,json=geteffectiveconfigResponse,proto3,oneof"`
+}
+
 func (*Response_Status) isResponse_Response() {}
 
 func (*Response_GreetResponse) isResponse_Response() {}
 
+func (*Response_GeteffectiveconfigResponse) isResponse_Response() {}
+
 var File_internal_server_servertest_client_client_proto protoreflect.FileDescriptor
 
 var file_internal_server_servertest_client_client_proto_rawDesc = []byte{
@@ -179,30 +205,46 @@ var file_internal_server_servertest_client_client_proto_rawDesc = []byte{
 	0x12, 0x1b, 0x63, 0x6d, 0x64, 0x2e, 0x61, 0x63, 0x68, 0x65, 0x77, 0x2e, 0x74, 0x6f, 0x79, 0x70,
 	0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x1a, 0x17, 0x67,
 	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x72, 0x70, 0x63, 0x2f, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
-	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x17, 0x61, 0x70, 0x69, 0x2f, 0x76, 0x31, 0x2f, 0x68,
-	0x65, 0x6c, 0x6c, 0x6f, 0x77, 0x6f, 0x72, 0x6c, 0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
-	0x66, 0x0a, 0x07, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x50, 0x0a, 0x0d, 0x67, 0x72,
-	0x65, 0x65, 0x74, 0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x29, 0x2e, 0x63, 0x6d, 0x64, 0x2e, 0x61, 0x63, 0x68, 0x65, 0x77, 0x2e, 0x74, 0x6f,
-	0x79, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e,
-	0x47, 0x72, 0x65, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x0c,
-	0x67, 0x72, 0x65, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x42, 0x09, 0x0a, 0x07,
-	0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x99, 0x01, 0x0a, 0x08, 0x52, 0x65, 0x73, 0x70,
-	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2c, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x72, 0x70,
-	0x63, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x48, 0x00, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74,
-	0x75, 0x73, 0x12, 0x53, 0x0a, 0x0e, 0x67, 0x72, 0x65, 0x65, 0x74, 0x5f, 0x72, 0x65, 0x73, 0x70,
-	0x6f, 0x6e, 0x73, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2a, 0x2e, 0x63, 0x6d, 0x64,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x12, 0x61, 0x70, 0x69, 0x2f, 0x76, 0x31, 0x2f, 0x61,
+	0x64, 0x6d, 0x69, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x17, 0x61, 0x70, 0x69, 0x2f,
+	0x76, 0x31, 0x2f, 0x68, 0x65, 0x6c, 0x6c, 0x6f, 0x77, 0x6f, 0x72, 0x6c, 0x64, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x22, 0xdf, 0x01, 0x0a, 0x07, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x50, 0x0a, 0x0d, 0x67, 0x72, 0x65, 0x65, 0x74, 0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x29, 0x2e, 0x63, 0x6d, 0x64, 0x2e, 0x61, 0x63, 0x68,
+	0x65, 0x77, 0x2e, 0x74, 0x6f, 0x79, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x2e, 0x61, 0x70,
+	0x69, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x72, 0x65, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x48, 0x00, 0x52, 0x0c, 0x67, 0x72, 0x65, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x77, 0x0a, 0x1a, 0x67, 0x65, 0x74, 0x65, 0x66, 0x66, 0x65, 0x63, 0x74, 0x69, 0x76,
+	0x65, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x36, 0x2e, 0x63, 0x6d, 0x64, 0x2e, 0x61, 0x63, 0x68, 0x65,
+	0x77, 0x2e, 0x74, 0x6f, 0x79, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x2e, 0x61, 0x70, 0x69,
+	0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x45, 0x66, 0x66, 0x65, 0x63, 0x74, 0x69, 0x76, 0x65,
+	0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52,
+	0x19, 0x67, 0x65, 0x74, 0x65, 0x66, 0x66, 0x65, 0x63, 0x74, 0x69, 0x76, 0x65, 0x63, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x42, 0x09, 0x0a, 0x07, 0x72, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x95, 0x02, 0x0a, 0x08, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x2c, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x12, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x72, 0x70, 0x63, 0x2e,
+	0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x48, 0x00, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x12, 0x53, 0x0a, 0x0e, 0x67, 0x72, 0x65, 0x65, 0x74, 0x5f, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2a, 0x2e, 0x63, 0x6d, 0x64, 0x2e, 0x61,
+	0x63, 0x68, 0x65, 0x77, 0x2e, 0x74, 0x6f, 0x79, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x2e,
+	0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x72, 0x65, 0x65, 0x74, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x0d, 0x67, 0x72, 0x65, 0x65, 0x74, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x7a, 0x0a, 0x1b, 0x67, 0x65, 0x74, 0x65, 0x66, 0x66, 0x65,
+	0x63, 0x74, 0x69, 0x76, 0x65, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x5f, 0x72, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x37, 0x2e, 0x63, 0x6d, 0x64,
 	0x2e, 0x61, 0x63, 0x68, 0x65, 0x77, 0x2e, 0x74, 0x6f, 0x79, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63,
-	0x74, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x72, 0x65, 0x65, 0x74, 0x52, 0x65,
-	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x0d, 0x67, 0x72, 0x65, 0x65, 0x74, 0x52,
-	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x0a, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x70, 0x6f,
-	0x6e, 0x73, 0x65, 0x42, 0x49, 0x5a, 0x47, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
-	0x6d, 0x2f, 0x61, 0x63, 0x68, 0x65, 0x77, 0x32, 0x32, 0x2f, 0x74, 0x6f, 0x79, 0x2d, 0x70, 0x72,
-	0x6f, 0x6a, 0x65, 0x63, 0x74, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x73,
-	0x65, 0x72, 0x76, 0x65, 0x72, 0x2f, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x74, 0x65, 0x73, 0x74,
-	0x2f, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x3b, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x74, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x45, 0x66, 0x66, 0x65,
+	0x63, 0x74, 0x69, 0x76, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x1a, 0x67, 0x65, 0x74, 0x65, 0x66, 0x66, 0x65, 0x63, 0x74,
+	0x69, 0x76, 0x65, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x42, 0x0a, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x49, 0x5a,
+	0x47, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x61, 0x63, 0x68, 0x65,
+	0x77, 0x32, 0x32, 0x2f, 0x74, 0x6f, 0x79, 0x2d, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x2f,
+	0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2f,
+	0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x74, 0x65, 0x73, 0x74, 0x2f, 0x63, 0x6c, 0x69, 0x65, 0x6e,
+	0x74, 0x3b, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -219,21 +261,25 @@ func file_internal_server_servertest_client_client_proto_rawDescGZIP() []byte {
 
 var file_internal_server_servertest_client_client_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
 var file_internal_server_servertest_client_client_proto_goTypes = []any{
-	(*Request)(nil),          // 0: cmd.achew.toyproject.api.v1.Request
-	(*Response)(nil),         // 1: cmd.achew.toyproject.api.v1.Response
-	(*v1.GreetRequest)(nil),  // 2: cmd.achew.toyproject.api.v1.GreetRequest
-	(*status.Status)(nil),    // 3: google.rpc.Status
-	(*v1.GreetResponse)(nil), // 4: cmd.achew.toyproject.api.v1.GreetResponse
+	(*Request)(nil),                       // 0: cmd.achew.toyproject.api.v1.Request
+	(*Response)(nil),                      // 1: cmd.achew.toyproject.api.v1.Response
+	(*v1.GreetRequest)(nil),               // 2: cmd.achew.toyproject.api.v1.GreetRequest
+	(*v1.GetEffectiveConfigRequest)(nil),  // 3: cmd.achew.toyproject.api.v1.GetEffectiveConfigRequest
+	(*status.Status)(nil),                 // 4: google.rpc.Status
+	(*v1.GreetResponse)(nil),              // 5: cmd.achew.toyproject.api.v1.GreetResponse
+	(*v1.GetEffectiveConfigResponse)(nil), // 6: cmd.achew.toyproject.api.v1.GetEffectiveConfigResponse
 }
 var file_internal_server_servertest_client_client_proto_depIdxs = []int32{
 	2, // 0: cmd.achew.toyproject.api.v1.Request.greet_request:type_name -> cmd.achew.toyproject.api.v1.GreetRequest
-	3, // 1: cmd.achew.toyproject.api.v1.Response.status:type_name -> google.rpc.Status
-	4, // 2: cmd.achew.toyproject.api.v1.Response.greet_response:type_name -> cmd.achew.toyproject.api.v1.GreetResponse
-	3, // [3:3] is the sub-list for method output_type
-	3, // [3:3] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	3, // 1: cmd.achew.toyproject.api.v1.Request.geteffectiveconfig_request:type_name -> cmd.achew.toyproject.api.v1.GetEffectiveConfigRequest
+	4, // 2: cmd.achew.toyproject.api.v1.Response.status:type_name -> google.rpc.Status
+	5, // 3: cmd.achew.toyproject.api.v1.Response.greet_response:type_name -> cmd.achew.toyproject.api.v1.GreetResponse
+	6, // 4: cmd.achew.toyproject.api.v1.Response.geteffectiveconfig_response:type_name -> cmd.achew.toyproject.api.v1.GetEffectiveConfigResponse
+	5, // [5:5] is the sub-list for method output_type
+	5, // [5:5] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
 }
 
 func init() { file_internal_server_servertest_client_client_proto_init() }
@@ -269,10 +315,12 @@ func file_internal_server_servertest_client_client_proto_init() {
 	}
 	file_internal_server_servertest_client_client_proto_msgTypes[0].OneofWrappers = []any{
 		(*Request_GreetRequest)(nil),
+		(*Request_GeteffectiveconfigRequest)(nil),
 	}
 	file_internal_server_servertest_client_client_proto_msgTypes[1].OneofWrappers = []any{
 		(*Response_Status)(nil),
 		(*Response_GreetResponse)(nil),
+		(*Response_GeteffectiveconfigResponse)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
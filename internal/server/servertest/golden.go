@@ -3,11 +3,13 @@ package servertest
 import (
 	"context"
 	"testing"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/protobuf/encoding/prototext"
 
 	"github.com/achew22/toy-project/internal/goldentest"
+	"github.com/achew22/toy-project/internal/server"
 	"github.com/achew22/toy-project/internal/server/servertest/client"
 
 	pb "github.com/achew22/toy-project/internal/server/servertest/proto/v1"
@@ -20,57 +22,113 @@ type serverFixture struct {
 	Conn   *grpc.ClientConn
 }
 
-var testSuite = goldentest.NewStepConfig(
-	func(ctx context.Context, fixture *serverFixture, stepFile goldentest.StepFile) (*pb.TestStepOut, error) {
-		// Parse the input step
-		stepIn := &pb.TestStepIn{}
-		if err := prototext.Unmarshal(stepFile.Data, stepIn); err != nil {
-			return nil, err
-		}
-
-		// Execute the RPC
-		response, err := fixture.Client.Execute(ctx, stepIn.Rpc)
-		if err != nil {
-			return nil, err
-		}
-
-		// Create the output step
-		stepOut := &pb.TestStepOut{
-			Rpc: response,
-		}
-		return stepOut, nil
-	},
-).
-	WithInputExt(".textpb").
-	WithSetUp(func(t *testing.T) (*serverFixture, error) {
-		// Start the server once for all test steps
-		server := New(t.Context())
-
-		// Create client connection
-		conn, err := server.NewClientConn(context.Background())
-		if err != nil {
-			server.Close()
-			return nil, err
-		}
-
-		// Create the unified client
-		grpcClient := client.NewClient(conn)
-		return &serverFixture{
-			Server: server,
-			Client: grpcClient,
-			Conn:   conn,
-		}, nil
-	}).
-	WithTearDown(func(t *testing.T, fixture *serverFixture) error {
-		fixture.Conn.Close()
-		fixture.Server.Close()
-		return nil
-	}).
-	Build()
+// newServerFixture starts a server created with opts, which stops when ctx is
+// done, and connects a client to it.
+func newServerFixture(ctx context.Context, opts ...server.Option) (*serverFixture, error) {
+	server := New(ctx, opts...)
+
+	// Create client connection
+	conn, err := server.NewClientConn(context.Background())
+	if err != nil {
+		server.Close()
+		return nil, err
+	}
+
+	// Create the unified client
+	grpcClient := client.NewClient(conn)
+	return &serverFixture{
+		Server: server,
+		Client: grpcClient,
+		Conn:   conn,
+	}, nil
+}
+
+// Reset replaces the client connection with a new one, so that a test case
+// does not see the connection state, such as open streams, of the one before.
+// Servers keep no state between connections.
+func (f *serverFixture) Reset() error {
+	conn, err := f.Server.NewClientConn(context.Background())
+	if err != nil {
+		return err
+	}
+	f.Conn.Close()
+	f.Conn = conn
+	f.Client = client.NewClient(conn)
+	return nil
+}
+
+// Close closes the client connection and stops the server.
+func (f *serverFixture) Close() error {
+	f.Conn.Close()
+	f.Server.Close()
+	return nil
+}
+
+// testSuite returns the golden step test config for servers created with
+// opts.
+func testSuite(opts ...server.Option) *goldentest.TestConfig[*pb.TestStepOut, *serverFixture] {
+	return goldentest.NewStepConfig(
+		func(ctx context.Context, fixture *serverFixture, stepFile goldentest.StepFile) (*pb.TestStepOut, error) {
+			// Parse the input step
+			stepIn := &pb.TestStepIn{}
+			if err := prototext.Unmarshal(stepFile.Data, stepIn); err != nil {
+				return nil, err
+			}
+
+			// Execute the RPC
+			response, err := fixture.Client.Execute(ctx, stepIn.Rpc)
+			if err != nil {
+				return nil, err
+			}
+
+			// Create the output step
+			stepOut := &pb.TestStepOut{
+				Rpc: response,
+			}
+			return stepOut, nil
+		},
+	).
+		WithInputExt(".textpb").
+		WithRecursive().
+		WithCapture(goldentest.CaptureProtoField[*pb.TestStepOut]()).
+		WithParallel(0).
+		WithStepTimeout(10 * time.Second).
+		WithSetUpSuite(func(ctx context.Context) (*serverFixture, error) {
+			return newServerFixture(ctx, opts...)
+		}).
+		WithReset(func(t *testing.T, fixture *serverFixture) error {
+			return fixture.Reset()
+		}).
+		WithTearDownSuite(func(fixture *serverFixture) error {
+			return fixture.Close()
+		}).
+		WithBenchSetUp(func(b *testing.B) (*serverFixture, error) {
+			return newServerFixture(b.Context(), opts...)
+		}).
+		WithBenchTearDown(func(b *testing.B, fixture *serverFixture) error {
+			return fixture.Close()
+		}).
+		Build()
+}
 
 // RunGoldenStepTests runs golden step tests for gRPC server interactions.
-// It starts a server once and reuses it across all test steps.
-// Each step consists of a TestStepIn input and produces a TestStepOut output.
+// Test cases may be grouped in nested directories of testdata.
+// Servers are started once and shared by test cases, one for each test case
+// running in parallel. Each step consists of a TestStepIn input and produces a
+// TestStepOut output.
 func RunGoldenStepTests(t *testing.T) {
-	testSuite.RunTests(t, "testdata")
+	RunGoldenStepTestsDir(t, "testdata")
+}
+
+// RunGoldenStepTestsDir is like RunGoldenStepTests, but runs the test cases in
+// dir against servers created with opts, such as a configuration.
+func RunGoldenStepTestsDir(t *testing.T, dir string, opts ...server.Option) {
+	testSuite(opts...).RunTests(t, dir)
+}
+
+// RunGoldenStepBenchmarks benchmarks the golden step test cases in testdata,
+// replaying the steps of each test case against a new server without
+// comparing their outputs.
+func RunGoldenStepBenchmarks(b *testing.B) {
+	testSuite().RunBenchmarks(b, "testdata")
 }
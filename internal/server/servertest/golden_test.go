@@ -8,3 +8,8 @@ import (
 func TestRunGoldenStepTests(t *testing.T) {
 	RunGoldenStepTests(t)
 }
+
+// BenchmarkRunGoldenStepBenchmarks benchmarks the golden step test cases.
+func BenchmarkRunGoldenStepBenchmarks(b *testing.B) {
+	RunGoldenStepBenchmarks(b)
+}
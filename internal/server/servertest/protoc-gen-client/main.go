@@ -1,7 +1,15 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"google.golang.org/protobuf/compiler/protogen"
@@ -9,7 +17,9 @@ import (
 )
 
 func main() {
-	protogen.Options{}.Run(func(gen *protogen.Plugin) error {
+	var flags flag.FlagSet
+	previous := flags.String("previous", "", "path of the client.proto generated before, whose field numbers are kept")
+	protogen.Options{ParamFunc: flags.Set}.Run(func(gen *protogen.Plugin) error {
 		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
 
 		// Find all services and their methods
@@ -25,9 +35,16 @@ func main() {
 			return nil
 		}
 
+		// Keep the field numbers of the previous client.proto so that
+		// adding a method does not renumber the fields of the others
+		numbers, err := readFieldNumbers(*previous)
+		if err != nil {
+			return err
+		}
+
 		// Generate the client.proto file
 		protoFile := gen.NewGeneratedFile("client.proto", "")
-		generateProtoFile(protoFile, services)
+		generateProtoFile(protoFile, services, numbers)
 
 		// Generate the client.go file
 		goFile := gen.NewGeneratedFile("client.go", "github.com/achew22/toy-project/internal/server/servertest/client")
@@ -37,7 +54,7 @@ func main() {
 	})
 }
 
-func generateProtoFile(g *protogen.GeneratedFile, services []*protogen.Service) {
+func generateProtoFile(g *protogen.GeneratedFile, services []*protogen.Service, numbers map[string]*fieldNumbers) {
 	g.P(`syntax = "proto3";`)
 	g.P()
 	g.P(`package cmd.achew.toyproject.api.v1;`)
@@ -62,38 +79,133 @@ func generateProtoFile(g *protogen.GeneratedFile, services []*protogen.Service)
 	g.P()
 
 	// Generate Request message with oneof for each method
-	g.P(`message Request {`)
-	g.P(`  oneof request {`)
-	fieldNum := 1
+	var requests []string
 	for _, service := range services {
 		for _, method := range service.Methods {
 			methodName := strings.ToLower(method.GoName)
 			typeName := string(method.Input.Desc.Name())
-			g.P(fmt.Sprintf(`    %s %s_request = %d;`, typeName, methodName, fieldNum))
-			fieldNum++
+			requests = append(requests, typeName+" "+methodName+"_request")
 		}
 	}
-	g.P(`  }`)
-	g.P(`}`)
+	generateMessage(g, "Request", "request", requests, numbers["Request"])
 	g.P()
 
 	// Generate Response message with oneof for each method plus status
-	g.P(`message Response {`)
-	g.P(`  oneof response {`)
-	g.P(`    google.rpc.Status status = 1;`)
-	fieldNum = 2
+	responses := []string{"google.rpc.Status status"}
 	for _, service := range services {
 		for _, method := range service.Methods {
 			methodName := strings.ToLower(method.GoName)
 			typeName := string(method.Output.Desc.Name())
-			g.P(fmt.Sprintf(`    %s %s_response = %d;`, typeName, methodName, fieldNum))
-			fieldNum++
+			responses = append(responses, typeName+" "+methodName+"_response")
+		}
+	}
+	generateMessage(g, "Response", "response", responses, numbers["Response"])
+}
+
+// generateMessage generates a message with a single oneof of fields, each a
+// type followed by a name. Fields keep their number in previous, new fields
+// are numbered after every number used before, and the numbers of removed
+// fields are reserved. Fields are ordered by number.
+func generateMessage(g *protogen.GeneratedFile, name, oneof string, fields []string, previous *fieldNumbers) {
+	if previous == nil {
+		previous = &fieldNumbers{numbers: map[string]int{}}
+	}
+	next := 1
+	for _, number := range previous.numbers {
+		next = max(next, number+1)
+	}
+	for _, number := range previous.reserved {
+		next = max(next, number+1)
+	}
+
+	numbers := map[string]int{}
+	for _, field := range fields {
+		fieldName := field[strings.LastIndex(field, " ")+1:]
+		if number, ok := previous.numbers[fieldName]; ok {
+			numbers[field] = number
+		} else {
+			numbers[field] = next
+			next++
+		}
+	}
+	reserved := slices.Clone(previous.reserved)
+	for fieldName, number := range previous.numbers {
+		if !slices.ContainsFunc(fields, func(field string) bool { return strings.HasSuffix(field, " "+fieldName) }) {
+			reserved = append(reserved, number)
+		}
+	}
+	slices.Sort(reserved)
+	slices.SortFunc(fields, func(a, b string) int { return numbers[a] - numbers[b] })
+
+	g.P(`message `, name, ` {`)
+	if len(reserved) > 0 {
+		var list []string
+		for _, number := range reserved {
+			list = append(list, strconv.Itoa(number))
 		}
+		g.P(`  reserved `, strings.Join(list, ", "), `;`)
+	}
+	g.P(`  oneof `, oneof, ` {`)
+	for _, field := range fields {
+		g.P(fmt.Sprintf(`    %s = %d;`, field, numbers[field]))
 	}
 	g.P(`  }`)
 	g.P(`}`)
 }
 
+// fieldNumbers are the field numbers of a message of a generated client.proto.
+type fieldNumbers struct {
+	// numbers holds the number of each field, keyed by its name.
+	numbers  map[string]int
+	reserved []int
+}
+
+var (
+	messagePattern  = regexp.MustCompile(`^message (\w+) \{$`)
+	fieldPattern    = regexp.MustCompile(`^\S+ (\w+) = (\d+);$`)
+	reservedPattern = regexp.MustCompile(`^reserved ([\d, ]+);$`)
+)
+
+// readFieldNumbers reads the field numbers of the messages of the client.proto
+// at path, as written by generateProtoFile, keyed by message name. It returns
+// no numbers if path is empty or does not exist.
+func readFieldNumbers(path string) (map[string]*fieldNumbers, error) {
+	messages := map[string]*fieldNumbers{}
+	if path == "" {
+		return messages, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return messages, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var message *fieldNumbers
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := messagePattern.FindStringSubmatch(line); m != nil {
+			message = &fieldNumbers{numbers: map[string]int{}}
+			messages[m[1]] = message
+		} else if message == nil {
+			continue
+		} else if m := fieldPattern.FindStringSubmatch(line); m != nil {
+			message.numbers[m[1]], _ = strconv.Atoi(m[2])
+		} else if m := reservedPattern.FindStringSubmatch(line); m != nil {
+			for _, number := range strings.Split(m[1], ",") {
+				n, err := strconv.Atoi(strings.TrimSpace(number))
+				if err != nil {
+					return nil, fmt.Errorf("%s: invalid reserved number %q", path, number)
+				}
+				message.reserved = append(message.reserved, n)
+			}
+		}
+	}
+	return messages, scanner.Err()
+}
+
 func generateGoFile(g *protogen.GeneratedFile, services []*protogen.Service) {
 	g.P(`// Package client provides a unified client interface for gRPC services.`)
 	g.P(`package client`)
@@ -19,16 +19,16 @@ type ServerTest struct {
 	cancel   context.CancelFunc
 }
 
-// New creates a new test gRPC server listening on a loopback address.
-// The server's lifecycle is tied to the provided context.
+// New creates a new test gRPC server listening on a loopback address,
+// configured by opts. The server's lifecycle is tied to the provided context.
 // It returns a ServerTest that can be used for testing gRPC services.
-func New(ctx context.Context) *ServerTest {
+func New(ctx context.Context, opts ...server.Option) *ServerTest {
 	lis, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		panic(err)
 	}
 
-	srv := server.NewServer()
+	srv := server.NewServer(opts...)
 
 	serverCtx, cancel := context.WithCancel(ctx)
 